@@ -0,0 +1,346 @@
+package libhosty
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// ConflictPolicy define a safe type for the behavior of AddHostsFileLineWithOptions
+// when the hostname is already mapped to a different address
+type ConflictPolicy int
+
+const (
+	//ReplaceExisting removes the hostname from its previous address (default),
+	//removal and addition are applied as a single OpReplace mutation
+	ReplaceExisting ConflictPolicy = iota
+
+	//ErrorOnConflict returns a *HostnameConflictError naming the conflicting row
+	ErrorOnConflict
+
+	//AllowMultiple keeps the previous mapping and adds the new one (dual-stack, round-robin)
+	AllowMultiple
+
+	//KeepExisting keeps the previous mapping and returns the existing line untouched
+	KeepExisting
+)
+
+func (cp ConflictPolicy) String() string {
+	switch cp {
+	case ReplaceExisting:
+		return "replace-existing"
+	case ErrorOnConflict:
+		return "error-on-conflict"
+	case AllowMultiple:
+		return "allow-multiple"
+	case KeepExisting:
+		return "keep-existing"
+	default:
+		return "conflict-policy-unknown"
+	}
+}
+
+// CommentMode define a safe type for the handling of comments on existing lines
+type CommentMode int
+
+const (
+	//CommentReplace replaces the existing comment with the given one, if not empty (default)
+	CommentReplace CommentMode = iota
+
+	//CommentAppend appends the given comment to the existing one
+	CommentAppend
+
+	//CommentKeep keeps the existing comment, the given one is used only if the line has none
+	CommentKeep
+)
+
+func (cm CommentMode) String() string {
+	switch cm {
+	case CommentReplace:
+		return "comment-replace"
+	case CommentAppend:
+		return "comment-append"
+	case CommentKeep:
+		return "comment-keep"
+	default:
+		return "comment-mode-unknown"
+	}
+}
+
+// AddOptions holds options for AddHostsFileLineWithOptions.
+// the zero value behaves like AddHostsFileLine
+type AddOptions struct {
+	//Conflict defines what to do if the hostname is already mapped to a different address
+	Conflict ConflictPolicy
+
+	//Comment defines how the comment is handled when an existing line is edited
+	Comment CommentMode
+
+	//Annotations are added to the line, replacing existing ones with the same key
+	Annotations map[string]string
+
+	//TTL sets the line to expire TTL after now, see PruneExpired
+	TTL time.Duration
+
+	//Expires sets the line to expire at the given time, it takes precedence over TTL
+	Expires time.Time
+
+	//Profile adds the line to the named profile, see ActivateProfile.
+	//names cannot contain commas, whitespace, # or =
+	Profile string
+}
+
+// AddHostsFileLineWithOptions add the given ip/fqdn/comment pair, conflicts with existing entries
+// and comments are handled as defined by opts.
+// only uncommented lines conflict, commented ones are left alone.
+// annotations that cannot be stored in a comment are rejected with ErrInvalidAnnotation.
+// it returns the index of the edited (created) line and a pointer to the hostsfileline object.
+// error is not nil if something goes wrong
+func (h *HostsFile) AddHostsFileLineWithOptions(ipRaw, fqdnRaw, comment string, opts AddOptions) (int, *HostsFileLine, error) {
+	// hostname to lowercase
+	hostname := strings.ToLower(fqdnRaw)
+	// parse ip to net.IP, validating it against the dialect
+	ip, zone, err := h.parseAddress(ipRaw)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	if opts.Profile != "" {
+		if err := validateProfile(opts.Profile); err != nil {
+			return -1, nil, err
+		}
+	}
+
+	// the expiry and the profile are stored as annotations
+	opts.Annotations = opts.annotations()
+	if err := validateAnnotations(opts.Annotations); err != nil {
+		return -1, nil, err
+	}
+
+	current := h.snapshot()
+
+	if opts.Conflict == AllowMultiple {
+		// other mappings are left alone, we are done if this one already exists
+		for idx, hfl := range current {
+			if !hfl.IsCommented && sameAddress(hfl, ip, zone) && slices.Contains(hfl.Hostnames, hostname) {
+				if err := h.applyComment(idx, comment, opts); err != nil {
+					return -1, nil, err
+				}
+
+				return idx, &h.HostsFileLines[idx], nil
+			}
+		}
+	} else if idx := mappingRow(current, ip, zone, hostname, false); idx >= 0 {
+		// if the hostname is already mapped to the given ip, we are done
+		if err := h.applyComment(idx, comment, opts); err != nil {
+			return -1, nil, err
+		}
+
+		return idx, &h.HostsFileLines[idx], nil
+	} else if idx := activeHostnameRow(current, hostname); idx >= 0 {
+		switch opts.Conflict {
+		case ErrorOnConflict:
+			return idx, &h.HostsFileLines[idx], &HostnameConflictError{
+				Hostname: hostname,
+				Row:      idx,
+				Line:     current[idx],
+			}
+		case KeepExisting:
+			return idx, &h.HostsFileLines[idx], nil
+		default:
+			// if address is different, we need to remove the hostname from the previous entry.
+			// removal and addition are a single mutation, so a veto leaves the previous mapping in place
+			lines, _ := stripHostname(cloneHostsFileLines(current), idx, hostname)
+
+			row, lines := h.placeHostname(lines, ip, zone, hostname, comment, opts)
+			if err := h.replaceLines(current, lines); err != nil {
+				return -1, nil, err
+			}
+
+			return row, &h.HostsFileLines[row], nil
+		}
+	}
+
+	// a commented mapping without active conflict is returned as it is, to be uncommented
+	if idx := mappingRow(current, ip, zone, hostname, true); idx >= 0 {
+		if err := h.applyComment(idx, comment, opts); err != nil {
+			return -1, nil, err
+		}
+
+		return idx, &h.HostsFileLines[idx], nil
+	}
+
+	row, appended := h.hostnameRow(current, ip, zone, hostname)
+
+	if appended {
+		hfl := withHostname(current[row], hostname, comment, opts)
+
+		if err := h.updateLines(OpModify, []int{row}, current[row:row+1], []HostsFileLine{hfl}); err != nil {
+			return -1, nil, err
+		}
+
+		// return edited entry
+		return row, &h.HostsFileLines[row], nil
+	}
+
+	if err := h.insertLines(row, newAddressLine(ip, zone, hostname, comment, opts)); err != nil {
+		return -1, nil, err
+	}
+
+	return row, &h.HostsFileLines[row], nil
+}
+
+// hostnameRow returns where hostname is added for the given address in lines.
+// appended is true if row is an uncommented line of the same address with room for the hostname,
+// otherwise row is where a new line is inserted
+func (h *HostsFile) hostnameRow(lines []HostsFileLine, ip net.IP, zone, hostname string) (row int, appended bool) {
+	// index saves the last matching index for the next for loop
+	// in this way, if we find a matching line (same IP)
+	// but needs to create a new line (hostname limit exceeded)
+	// it would be nice to place the new line next to the existing one
+	index := -1
+
+	// we don't have the fqdn
+	// if we already have the address, just add the hostname to that line
+	for idx, hfl := range lines {
+		if hfl.Type != LineTypeAddress || hfl.IsCommented || !sameAddress(hfl, ip, zone) {
+			continue
+		}
+
+		// if the line is full, just continue
+		// we'll either find another matching line
+		// or we'll end up creating a new line
+		if !h.limits().canAppend(hfl, hostname) {
+			// save index
+			index = idx
+			continue
+		}
+
+		return idx, true
+	}
+
+	// if we found a matching line (index != -1)
+	// place the new line next to the matched one
+	// else append to hosts
+	if index != -1 {
+		return index + 1, false
+	}
+
+	return len(lines), false
+}
+
+// placeHostname adds hostname to lines as AddHostsFileLineWithOptions does, without conflict handling.
+// it returns the row holding the hostname and the resulting lines
+func (h *HostsFile) placeHostname(lines []HostsFileLine, ip net.IP, zone, hostname, comment string, opts AddOptions) (int, []HostsFileLine) {
+	row, appended := h.hostnameRow(lines, ip, zone, hostname)
+
+	if appended {
+		lines[row] = withHostname(lines[row], hostname, comment, opts)
+		lines[row].Raw = lineFormatter(lines[row])
+
+		return row, lines
+	}
+
+	return row, slices.Insert(lines, row, newAddressLine(ip, zone, hostname, comment, opts))
+}
+
+// withHostname returns a copy of hfl with hostname added, its comment and annotations merged as defined by opts
+func withHostname(hfl HostsFileLine, hostname, comment string, opts AddOptions) HostsFileLine {
+	hfl.Hostnames = append(append([]string{}, hfl.Hostnames...), hostname)
+	hfl.Comment = mergeComment(hfl.Comment, comment, opts.Comment)
+	hfl.Annotations = nilIfEmpty(mergeAnnotations(hfl.Annotations, opts.Annotations))
+
+	return hfl
+}
+
+// newAddressLine returns a new uncommented address line mapping hostname to the given address
+func newAddressLine(ip net.IP, zone, hostname, comment string, opts AddOptions) HostsFileLine {
+	hfl := HostsFileLine{
+		Type:        LineTypeAddress,
+		Address:     ip,
+		Zone:        zone,
+		Hostnames:   []string{hostname},
+		Raw:         "",
+		Comment:     comment,
+		Annotations: nilIfEmpty(mergeAnnotations(nil, opts.Annotations)),
+		IsCommented: false,
+	}
+
+	// generate raw version of the line
+	hfl.Raw = lineFormatter(hfl)
+
+	return hfl
+}
+
+// mappingRow returns the row of the first address line mapping hostname to the given address,
+// commented as given, -1 if there is none
+func mappingRow(lines []HostsFileLine, ip net.IP, zone, hostname string, commented bool) int {
+	for idx, hfl := range lines {
+		if hfl.Type == LineTypeAddress && hfl.IsCommented == commented && sameAddress(hfl, ip, zone) && slices.Contains(hfl.Hostnames, hostname) {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+// activeHostnameRow returns the row of the first uncommented address line mapping hostname, -1 if there is none
+func activeHostnameRow(lines []HostsFileLine, hostname string) int {
+	for idx, hfl := range lines {
+		if hfl.Type == LineTypeAddress && !hfl.IsCommented && slices.Contains(hfl.Hostnames, hostname) {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+// applyComment updates the comment and the annotations of the given row as defined by opts
+func (h *HostsFile) applyComment(row int, comment string, opts AddOptions) error {
+	hfl := h.linesAt([]int{row})[0]
+
+	merged := mergeComment(hfl.Comment, comment, opts.Comment)
+	annotations := mergeAnnotations(hfl.Annotations, opts.Annotations)
+	if merged == hfl.Comment && equalAnnotations(annotations, hfl.Annotations) {
+		return nil
+	}
+
+	hfl.Comment = merged
+	hfl.Annotations = nilIfEmpty(annotations)
+
+	return h.updateRows(OpModify, []int{row}, []HostsFileLine{hfl})
+}
+
+// mergeComment returns the comment resulting from applying comment to current as defined by mode
+func mergeComment(current, comment string, mode CommentMode) string {
+	if comment == "" {
+		return current
+	}
+
+	switch mode {
+	case CommentAppend:
+		if current != "" && !slices.Contains(commentParts(current), comment) {
+			comment = current + "; " + comment
+		} else if current != "" {
+			comment = current
+		}
+	case CommentKeep:
+		if current != "" {
+			comment = current
+		}
+	}
+
+	return comment
+}
+
+// commentParts splits a comment built by CommentAppend in its "; " separated parts
+func commentParts(comment string) []string {
+	parts := strings.Split(comment, ";")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	return parts
+}
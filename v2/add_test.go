@@ -0,0 +1,198 @@
+package libhosty
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAddHostsFileLineWithOptionsConflict(t *testing.T) {
+	newHostsFile := func() *HostsFile {
+		h := &HostsFile{}
+		h.AddHostsFileLine("10.0.0.1", "svc.local", "first")
+		return h
+	}
+
+	// ReplaceExisting moves the hostname
+	h := newHostsFile()
+	idx, _, err := h.AddHostsFileLineWithOptions("10.0.0.2", "svc.local", "", AddOptions{Conflict: ReplaceExisting})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.HostsFileLines) != 1 || h.HostsFileLines[idx].Address.String() != "10.0.0.2" {
+		t.Fatalf("hostname should be moved to 10.0.0.2: %v", h.HostsFileLines)
+	}
+
+	// ErrorOnConflict names the conflicting row
+	h = newHostsFile()
+	_, _, err = h.AddHostsFileLineWithOptions("10.0.0.2", "svc.local", "", AddOptions{Conflict: ErrorOnConflict})
+	var conflict *HostnameConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("wants *HostnameConflictError got %v", err)
+	}
+	if conflict.Row != 0 || conflict.Line.Address.String() != "10.0.0.1" {
+		t.Fatalf("unexpected conflict: %v", conflict)
+	}
+	if len(h.HostsFileLines) != 1 {
+		t.Fatalf("hosts file should be untouched: %v", h.HostsFileLines)
+	}
+
+	// AllowMultiple keeps both mappings
+	h = newHostsFile()
+	_, _, err = h.AddHostsFileLineWithOptions("fd00::1", "svc.local", "", AddOptions{Conflict: AllowMultiple})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.GetHostsFileLinesByHostname("svc.local")) != 2 {
+		t.Fatalf("wants %d lines got %d", 2, len(h.GetHostsFileLinesByHostname("svc.local")))
+	}
+
+	// KeepExisting returns the existing line
+	h = newHostsFile()
+	idx, hfl, err := h.AddHostsFileLineWithOptions("10.0.0.2", "svc.local", "", AddOptions{Conflict: KeepExisting})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx != 0 || hfl.Address.String() != "10.0.0.1" || len(h.HostsFileLines) != 1 {
+		t.Fatalf("existing mapping should be kept: %v", h.HostsFileLines)
+	}
+}
+
+func TestAddHostsFileLineWithOptionsComment(t *testing.T) {
+	cases := []struct {
+		mode CommentMode
+		want string
+	}{
+		{CommentReplace, "second"},
+		{CommentAppend, "first; second"},
+		{CommentKeep, "first"},
+	}
+
+	for _, c := range cases {
+		h := &HostsFile{}
+		h.AddHostsFileLine("10.0.0.1", "a.local", "first")
+
+		idx, _, err := h.AddHostsFileLineWithOptions("10.0.0.1", "b.local", "second", AddOptions{Comment: c.mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := h.HostsFileLines[idx].Comment; got != c.want {
+			t.Fatalf("%s: wants %q got %q", c.mode, c.want, got)
+		}
+	}
+}
+
+func TestMergeCommentAppend(t *testing.T) {
+	cases := []struct {
+		current string
+		comment string
+		want    string
+	}{
+		{"", "db", "db"},
+		{"primary db", "db", "primary db; db"},
+		{"primary; db", "db", "primary; db"},
+		{"db", "db", "db"},
+	}
+
+	for _, c := range cases {
+		if got := mergeComment(c.current, c.comment, CommentAppend); got != c.want {
+			t.Fatalf("mergeComment(%q, %q): wants %q got %q", c.current, c.comment, c.want, got)
+		}
+	}
+}
+
+func TestAddHostsFileLineWithOptionsCommentedConflict(t *testing.T) {
+	newHostsFile := func() *HostsFile {
+		h, err := InitFromString("# 10.0.0.9 db\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	// commented lines do not conflict
+	for _, conflict := range []ConflictPolicy{ReplaceExisting, ErrorOnConflict, KeepExisting} {
+		h := newHostsFile()
+
+		idx, hfl, err := h.AddHostsFileLineWithOptions("10.0.0.1", "db", "", AddOptions{Conflict: conflict})
+		if err != nil {
+			t.Fatalf("%s: %v", conflict, err)
+		}
+
+		if hfl.IsCommented || hfl.Address.String() != "10.0.0.1" {
+			t.Fatalf("%s: expected a new mapping, got %v", conflict, *hfl)
+		}
+
+		if h.HostsFileLines[0].Address.String() != "10.0.0.9" || !h.HostsFileLines[0].IsCommented || idx == 0 {
+			t.Fatalf("%s: commented line should be untouched: %v", conflict, h.HostsFileLines)
+		}
+	}
+}
+
+func TestAddHostsFileLineWithOptionsCommentedMapping(t *testing.T) {
+	newHostsFile := func() *HostsFile {
+		h, err := InitFromString("# 10.0.0.1 foo\n10.0.0.2 foo\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	// the commented mapping does not hide the active conflict
+	h := newHostsFile()
+	var conflict *HostnameConflictError
+	if _, _, err := h.AddHostsFileLineWithOptions("10.0.0.1", "foo", "", AddOptions{Conflict: ErrorOnConflict}); !errors.As(err, &conflict) || conflict.Row != 1 {
+		t.Fatalf("expected a conflict at row 1, got %v", err)
+	}
+
+	h = newHostsFile()
+	if idx, _, err := h.AddHostsFileLineWithOptions("10.0.0.1", "foo", "", AddOptions{Conflict: KeepExisting}); err != nil || idx != 1 {
+		t.Fatalf("expected the existing row 1, got %d %v", idx, err)
+	}
+
+	h = newHostsFile()
+	_, hfl, err := h.AddHostsFileLineWithOptions("10.0.0.1", "foo", "", AddOptions{Conflict: ReplaceExisting})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hfl.IsCommented || hfl.Address.String() != "10.0.0.1" {
+		t.Fatalf("expected an active mapping to 10.0.0.1, got %v", *hfl)
+	}
+
+	for _, l := range h.GetHostsFileLinesByHostname("foo") {
+		if !l.IsCommented && l.Address.String() != "10.0.0.1" {
+			t.Fatalf("expected foo to be removed from %s", l.Address)
+		}
+	}
+
+	// without active conflict the commented mapping is returned
+	h, err = InitFromString("# 10.0.0.1 foo\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if idx, hfl, err := h.AddHostsFileLineWithOptions("10.0.0.1", "foo", "", AddOptions{Conflict: ErrorOnConflict}); err != nil || idx != 0 || !hfl.IsCommented {
+		t.Fatalf("expected the commented row 0, got %d %v", idx, err)
+	}
+}
+
+func TestAddHostsFileLineWithOptionsReplaceVeto(t *testing.T) {
+	h := New(WithPolicy(&Policy{
+		Deny: []DenyRule{{Domain: "bank.com", Allow: []string{"10.0.0.1"}}},
+	}))
+
+	if _, _, err := h.AddHostsFileLine("10.0.0.1", "bank.com", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var violation *PolicyViolationError
+	if _, _, err := h.AddHostsFileLine("6.6.6.6", "bank.com", ""); !errors.As(err, &violation) || violation.Rule != RuleDeny {
+		t.Fatalf("expected a deny violation, got %v", err)
+	}
+
+	// the previous mapping is kept
+	if _, ip, err := h.LookupByHostname("bank.com"); err != nil || ip.String() != "10.0.0.1" {
+		t.Fatalf("bank.com should still map to 10.0.0.1, got %v %v", ip, err)
+	}
+}
@@ -0,0 +1,222 @@
+package libhosty
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// annotationPrefix starts the annotations in the comment of an address line:
+// 10.0.0.1 db # primary database # libhosty: owner=payments ticket=OPS-12
+const annotationPrefix = "libhosty:"
+
+// SelectAnnotation returns a Selector that matches lines annotated with the given key and value.
+// an empty value matches every line holding the key
+func SelectAnnotation(key, value string) Selector {
+	return func(_ int, hfl *HostsFileLine) bool {
+		v, ok := hfl.Annotations[key]
+		return ok && (value == "" || v == value)
+	}
+}
+
+// GetHostsFileLinesByAnnotation returns every line annotated with the given key and value.
+// an empty value matches every line holding the key
+func (h *HostsFile) GetHostsFileLinesByAnnotation(key, value string) []*HostsFileLine {
+	return h.GetHostsFileLinesBySelector(SelectAnnotation(key, value))
+}
+
+// GetAnnotation returns the annotation of the given row for key, ok is false if there is none
+func (h *HostsFile) GetAnnotation(row int, key string) (value string, ok bool) {
+	if row < 0 || row >= len(h.HostsFileLines) {
+		return "", false
+	}
+
+	value, ok = h.HostsFileLines[row].Annotations[key]
+
+	return value, ok
+}
+
+// SetAnnotation sets the annotation key to value on the address line at the given row.
+// keys and values cannot hold spaces or # characters, keys cannot hold = characters.
+// error is not nil if the row is not an address line, the annotation is invalid
+// or a hook vetoes the change
+func (h *HostsFile) SetAnnotation(row int, key, value string) error {
+	if err := validateAnnotation(key, value); err != nil {
+		return err
+	}
+
+	return h.updateAnnotations(row, func(annotations map[string]string) {
+		annotations[key] = value
+	})
+}
+
+// RemoveAnnotation removes the annotation key from the address line at the given row.
+// error is not nil if the row is not an address line or a hook vetoes the change
+func (h *HostsFile) RemoveAnnotation(row int, key string) error {
+	return h.updateAnnotations(row, func(annotations map[string]string) {
+		delete(annotations, key)
+	})
+}
+
+// updateAnnotations applies update to the annotations of the given row, as an OpModify mutation.
+// nothing happens if update does not change them
+func (h *HostsFile) updateAnnotations(row int, update func(annotations map[string]string)) error {
+	if row < 0 || row >= len(h.HostsFileLines) || h.HostsFileLines[row].Type != LineTypeAddress {
+		return ErrNotAnAddressLine
+	}
+
+	hfl := h.linesAt([]int{row})[0]
+
+	annotations := mergeAnnotations(hfl.Annotations, nil)
+	update(annotations)
+
+	if equalAnnotations(annotations, hfl.Annotations) {
+		return nil
+	}
+
+	hfl.Annotations = nilIfEmpty(annotations)
+
+	return h.updateRows(OpModify, []int{row}, []HostsFileLine{hfl})
+}
+
+// parseAnnotations splits a comment in its human part and its annotations.
+// annotations start at the libhosty: marker, at the beginning of the comment or after a #.
+// fields without = are kept as keys with an empty value
+func parseAnnotations(comment string) (string, map[string]string) {
+	idx := annotationIndex(comment)
+	if idx < 0 {
+		return comment, nil
+	}
+
+	annotations := make(map[string]string)
+	for _, f := range strings.Fields(comment[idx+len(annotationPrefix):]) {
+		k, v, _ := strings.Cut(f, "=")
+		annotations[k] = v
+	}
+
+	human := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(comment[:idx]), "#"))
+
+	return human, annotations
+}
+
+// annotationIndex returns the index of the annotation marker in comment, -1 if there is none
+func annotationIndex(comment string) int {
+	offset := 0
+
+	for {
+		idx := strings.Index(comment[offset:], annotationPrefix)
+		if idx < 0 {
+			return -1
+		}
+
+		idx += offset
+
+		before := strings.TrimSpace(comment[:idx])
+		if before == "" || strings.HasSuffix(before, "#") {
+			return idx
+		}
+
+		offset = idx + len(annotationPrefix)
+	}
+}
+
+// formatComment returns the comment of hfl followed by its annotations, sorted by key.
+// annotations alone are returned with a leading space, to be rendered as "# libhosty: ..."
+func formatComment(hfl HostsFileLine) string {
+	if len(hfl.Annotations) == 0 {
+		return hfl.Comment
+	}
+
+	keys := make([]string, 0, len(hfl.Annotations))
+	for k := range hfl.Annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fields := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if v := hfl.Annotations[k]; v != "" {
+			fields = append(fields, k+"="+v)
+		} else {
+			fields = append(fields, k)
+		}
+	}
+
+	annotations := annotationPrefix + " " + strings.Join(fields, " ")
+
+	if hfl.Comment == "" {
+		return " " + annotations
+	}
+
+	return hfl.Comment + " # " + annotations
+}
+
+// cloneAnnotations returns a copy of annotations, nil if there are none
+func cloneAnnotations(annotations map[string]string) map[string]string {
+	return nilIfEmpty(mergeAnnotations(annotations, nil))
+}
+
+// nilIfEmpty returns annotations, nil if there are none
+func nilIfEmpty(annotations map[string]string) map[string]string {
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	return annotations
+}
+
+// validateAnnotation ensures key and value can be rendered and parsed back
+func validateAnnotation(key, value string) error {
+	if key == "" || strings.ContainsAny(key, "#=") || strings.ContainsRune(value, '#') ||
+		strings.ContainsFunc(key+value, unicode.IsSpace) {
+		return ErrInvalidAnnotation(key, value)
+	}
+
+	return nil
+}
+
+// validateAnnotations ensures every annotation can be rendered and parsed back, checking keys in order
+func validateAnnotations(annotations map[string]string) error {
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := validateAnnotation(k, annotations[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergeAnnotations returns a copy of current with annotations added
+func mergeAnnotations(current, annotations map[string]string) map[string]string {
+	res := make(map[string]string, len(current)+len(annotations))
+	for k, v := range current {
+		res[k] = v
+	}
+
+	for k, v := range annotations {
+		res[k] = v
+	}
+
+	return res
+}
+
+// equalAnnotations reports whether a and b hold the same annotations, nil and empty are equal
+func equalAnnotations(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+
+	return true
+}
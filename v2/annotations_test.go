@@ -0,0 +1,155 @@
+package libhosty
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAnnotations(t *testing.T) {
+	tests := []struct {
+		comment     string
+		human       string
+		annotations map[string]string
+	}{
+		{"primary db", "primary db", nil},
+		{"see libhosty: docs", "see libhosty: docs", nil},
+		{"libhosty: owner=payments", "", map[string]string{"owner": "payments"}},
+		{"primary db # libhosty: owner=payments ticket=OPS-12 pinned", "primary db", map[string]string{"owner": "payments", "ticket": "OPS-12", "pinned": ""}},
+		{"primary db #libhosty: owner=payments", "primary db", map[string]string{"owner": "payments"}},
+	}
+
+	for _, tt := range tests {
+		human, annotations := parseAnnotations(tt.comment)
+		if human != tt.human || !equalAnnotations(annotations, tt.annotations) {
+			t.Fatalf("%q: expected %q %v, got %q %v", tt.comment, tt.human, tt.annotations, human, annotations)
+		}
+	}
+}
+
+func TestAnnotationsRoundTrip(t *testing.T) {
+	content := "10.0.0.1 db # primary db # libhosty: owner=payments expires=2026-12-01\n10.0.0.2 web # libhosty: owner=frontend\n10.0.0.3 cache # just a comment"
+
+	h, err := InitFromString(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.HostsFileLines[0].Comment != "primary db" {
+		t.Fatalf("expected human comment to be kept, got %q", h.HostsFileLines[0].Comment)
+	}
+
+	if v, ok := h.GetAnnotation(0, "expires"); !ok || v != "2026-12-01" {
+		t.Fatalf("unexpected annotation %q %v", v, ok)
+	}
+
+	if lines := h.GetHostsFileLinesByAnnotation("owner", "payments"); len(lines) != 1 || lines[0].Hostnames[0] != "db" {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+
+	if lines := h.GetHostsFileLinesByAnnotation("owner", ""); len(lines) != 2 {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+
+	rendered := h.RenderHostsFile()
+	if !strings.Contains(rendered, "#primary db # libhosty: expires=2026-12-01 owner=payments") ||
+		!strings.Contains(rendered, "web # libhosty: owner=frontend") {
+		t.Fatalf("unexpected rendering %q", rendered)
+	}
+
+	reparsed, err := InitFromString(rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalHostsFileLinesSlice(h.HostsFileLines, reparsed.HostsFileLines) {
+		t.Fatalf("annotations lost in %q", rendered)
+	}
+}
+
+func TestSetAnnotation(t *testing.T) {
+	h, err := InitFromString("# hosts\n10.0.0.1 db # primary db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.SetAnnotation(1, "owner", "payments"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.SetAnnotation(1, "owner", "two words"); err == nil {
+		t.Fatal("expected an invalid annotation error")
+	}
+
+	if err := h.SetAnnotation(0, "owner", "payments"); err != ErrNotAnAddressLine {
+		t.Fatalf("expected ErrNotAnAddressLine, got %v", err)
+	}
+
+	if got := h.RenderHostsFileLine(1); !strings.HasSuffix(got, "#primary db # libhosty: owner=payments") {
+		t.Fatalf("unexpected rendering %q", got)
+	}
+
+	if err := h.RemoveAnnotation(1, "owner"); err != nil {
+		t.Fatal(err)
+	}
+
+	if h.HostsFileLines[1].Annotations != nil || h.HostsFileLines[1].Comment != "primary db" {
+		t.Fatalf("unexpected line %v", h.HostsFileLines[1])
+	}
+}
+
+func TestAddWithAnnotations(t *testing.T) {
+	h := New(WithDialect(DialectGlibc))
+
+	opts := AddOptions{Annotations: map[string]string{"owner": "payments"}}
+
+	if _, _, err := h.AddHostsFileLineWithOptions("10.0.0.1", "db", "", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Annotations = map[string]string{"ticket": "OPS-12"}
+
+	idx, _, err := h.AddHostsFileLineWithOptions("10.0.0.1", "db", "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalAnnotations(h.HostsFileLines[idx].Annotations, map[string]string{"owner": "payments", "ticket": "OPS-12"}) {
+		t.Fatalf("unexpected annotations %v", h.HostsFileLines[idx].Annotations)
+	}
+}
+
+func TestAddWithInvalidAnnotations(t *testing.T) {
+	h := New(WithDialect(DialectGlibc))
+
+	for _, annotations := range []map[string]string{
+		{"owner": "pay ments"},
+		{"bad key": "x"},
+		{"k=v": "x"},
+		{"note": "a#b"},
+		{"note": "a\nb"},
+	} {
+		_, _, err := h.AddHostsFileLineWithOptions("10.0.0.1", "db", "", AddOptions{Annotations: annotations})
+		if err == nil || !strings.Contains(err.Error(), "invalid annotation") {
+			t.Fatalf("%v: expected an invalid annotation error, got %v", annotations, err)
+		}
+	}
+
+	if len(h.HostsFileLines) != 0 {
+		t.Fatalf("expected no line to be added, got %v", h.HostsFileLines)
+	}
+
+	// valid annotations survive a round trip
+	annotations := map[string]string{"owner": "payments", "ticket": "OPS-12,OPS-13", "reviewed": ""}
+	if _, _, err := h.AddHostsFileLineWithOptions("10.0.0.1", "db", "primary", AddOptions{Annotations: annotations}); err != nil {
+		t.Fatal(err)
+	}
+
+	reparsed, err := InitFromString(h.RenderHostsFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hfl := reparsed.HostsFileLines[0]; !equalAnnotations(hfl.Annotations, annotations) || hfl.Comment != "primary" {
+		t.Fatalf("unexpected line %v", hfl)
+	}
+}
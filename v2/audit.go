@@ -0,0 +1,361 @@
+package libhosty
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditLogSuffix defines the default audit log name, appended to the hosts file path
+const auditLogSuffix = ".audit.jsonl"
+
+// AuditOperation holds a mutation recorded in the audit log
+type AuditOperation struct {
+	//Op is the kind of mutation, as returned by Operation.String
+	Op string `json:"op"`
+
+	//Rows are the affected rows, see Mutation
+	Rows []int `json:"rows"`
+
+	//Hostnames are the hostnames of the affected lines, before and after the mutation
+	Hostnames []string `json:"hostnames,omitempty"`
+
+	//Old are the affected lines before the mutation, rendered
+	Old []string `json:"old,omitempty"`
+
+	//New are the affected lines after the mutation, rendered
+	New []string `json:"new,omitempty"`
+}
+
+// AuditRecord holds a write of the hosts file, as recorded in the audit log
+type AuditRecord struct {
+	//Time is when the hosts file has been written
+	Time time.Time `json:"time"`
+
+	//Actor is who wrote the hosts file
+	Actor string `json:"actor"`
+
+	//Path is the written hosts file
+	Path string `json:"path"`
+
+	//Operations are the mutations applied since the previous load or write
+	Operations []AuditOperation `json:"operations"`
+
+	//Before are the rendered lines as they were loaded or previously written
+	Before []string `json:"before"`
+
+	//After are the rendered lines as they have been written
+	After []string `json:"after"`
+}
+
+// Content returns the hosts file content as it has been written
+func (r AuditRecord) Content() string {
+	return strings.Join(r.After, "\n")
+}
+
+// HostsFile returns the hosts file as it has been written, parsed
+func (r AuditRecord) HostsFile() (*HostsFile, error) {
+	return InitFromString(r.Content())
+}
+
+// AuditLog is a Hook recording every mutation of a hosts file,
+// and appending a JSON-lines record to its log each time the hosts file is written.
+// an AuditLog can be shared by several HostsFile, recorded mutations are kept
+// by each HostsFile until it is written
+type AuditLog struct {
+	//Path is the log path, empty means the hosts file path followed by .audit.jsonl
+	Path string
+
+	//Actor is recorded as the author of each write, empty means the current user
+	Actor string
+
+	// mu serializes writes to the log
+	mu sync.Mutex
+
+	// now returns the current time, replaced in tests
+	now func() time.Time
+}
+
+// NewAuditLog returns an AuditLog writing to path on behalf of actor,
+// empty values are defaulted as described by AuditLog
+func NewAuditLog(path, actor string) *AuditLog {
+	return &AuditLog{
+		Path:  path,
+		Actor: actor,
+	}
+}
+
+// Before accepts every mutation
+func (a *AuditLog) Before(h *HostsFile, m Mutation) error {
+	return nil
+}
+
+// After records m, until the hosts file is written
+func (a *AuditLog) After(h *HostsFile, m Mutation) {
+	op := AuditOperation{
+		Op:        m.Op.String(),
+		Rows:      append([]int{}, m.Rows...),
+		Hostnames: mutationHostnames(m),
+		Old:       renderLines(m.Old),
+		New:       renderLines(m.New),
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	if h.auditPending == nil {
+		h.auditPending = make(map[*AuditLog][]AuditOperation)
+	}
+
+	h.auditPending[a] = append(h.auditPending[a], op)
+}
+
+// afterWrite appends a record of the write to the log, with the pending mutations of h
+func (a *AuditLog) afterWrite(h *HostsFile, path string, data []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now
+	if a.now != nil {
+		now = a.now
+	}
+
+	h.Lock()
+	operations := h.auditPending[a]
+	base := cloneHostsFileLines(h.base)
+	h.Unlock()
+
+	// both sides are rendered as written, unchanged lines compare equal
+	before := splitRenderedLines(string(h.renderContent(base)))
+
+	rec := AuditRecord{
+		Time:       now().UTC(),
+		Actor:      a.actor(),
+		Path:       path,
+		Operations: operations,
+		Before:     before,
+		After:      splitRenderedLines(string(data)),
+	}
+
+	if rec.Operations == nil {
+		rec.Operations = []AuditOperation{}
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	logPath := a.Path
+	if logPath == "" {
+		logPath = path + auditLogSuffix
+	}
+
+	if err := appendFile(h.filesystem(), logPath, append(line, '\n'), 0600); err != nil {
+		return err
+	}
+
+	// mutations recorded during the write are kept for the next one
+	h.Lock()
+	if rest := h.auditPending[a][len(operations):]; len(rest) > 0 {
+		h.auditPending[a] = rest
+	} else {
+		delete(h.auditPending, a)
+	}
+	h.Unlock()
+
+	return nil
+}
+
+// actor returns the configured actor, or the current user
+func (a *AuditLog) actor() string {
+	if a.Actor != "" {
+		return a.Actor
+	}
+
+	return currentUser()
+}
+
+// currentUser returns the name of the user running the process, "unknown" if it cannot be found
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	if u := os.Getenv("USER"); u != "" {
+		return u
+	}
+
+	return "unknown"
+}
+
+// ReadAuditLog reads every record of the audit log at path.
+// error is not nil if something goes wrong
+func ReadAuditLog(path string) ([]AuditRecord, error) {
+	return ReadAuditLogFS(OSFS{}, path)
+}
+
+// ReadAuditLogFS reads every record of the audit log at path from the given FS.
+// error is not nil if something goes wrong
+func ReadAuditLogFS(fsys FS, path string) ([]AuditRecord, error) {
+	data, err := fsys.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []AuditRecord{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]AuditRecord, 0)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var rec AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, err
+		}
+
+		records = append(records, rec)
+	}
+
+	return records, scanner.Err()
+}
+
+// AuditQuery selects audit records, zero fields match every record
+type AuditQuery struct {
+	//Actor matches records written by the given actor
+	Actor string
+
+	//Since matches records written at or after the given time
+	Since time.Time
+
+	//Until matches records written at or before the given time
+	Until time.Time
+
+	//Hostname matches records with an operation affecting the given hostname
+	Hostname string
+
+	//Op matches records with an operation of the given kind, as returned by Operation.String
+	Op string
+}
+
+// Match reports whether the record matches the query
+func (q AuditQuery) Match(r AuditRecord) bool {
+	if q.Actor != "" && r.Actor != q.Actor {
+		return false
+	}
+
+	if !q.Since.IsZero() && r.Time.Before(q.Since) {
+		return false
+	}
+
+	if !q.Until.IsZero() && r.Time.After(q.Until) {
+		return false
+	}
+
+	if q.Hostname == "" && q.Op == "" {
+		return true
+	}
+
+	hostname := normalizeHostname(q.Hostname)
+
+	for _, op := range r.Operations {
+		if q.Op != "" && op.Op != q.Op {
+			continue
+		}
+
+		if q.Hostname == "" {
+			return true
+		}
+
+		for _, hn := range op.Hostnames {
+			if normalizeHostname(hn) == hostname {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// QueryAuditRecords returns the records matching the query, in log order
+func QueryAuditRecords(records []AuditRecord, q AuditQuery) []AuditRecord {
+	res := make([]AuditRecord, 0)
+
+	for _, r := range records {
+		if q.Match(r) {
+			res = append(res, r)
+		}
+	}
+
+	return res
+}
+
+// ReconstructAt returns the hosts file as it was at the given time,
+// from the last record written at or before it.
+// error is ErrNoAuditRecord if no record was written before the given time
+func ReconstructAt(records []AuditRecord, at time.Time) (*HostsFile, error) {
+	for i := len(records) - 1; i >= 0; i-- {
+		if !records[i].Time.After(at) {
+			return records[i].HostsFile()
+		}
+	}
+
+	return nil, ErrNoAuditRecord
+}
+
+// mutationHostnames returns the hostnames of the lines affected by m, without duplicates
+func mutationHostnames(m Mutation) []string {
+	res := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, lines := range [][]HostsFileLine{m.Old, m.New} {
+		for _, hfl := range lines {
+			for _, hn := range hfl.Hostnames {
+				if !seen[hn] {
+					seen[hn] = true
+					res = append(res, hn)
+				}
+			}
+		}
+	}
+
+	return res
+}
+
+// renderLines returns the given lines rendered with lineFormatter, nil if there are none
+func renderLines(lines []HostsFileLine) []string {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	res := make([]string, len(lines))
+	for i, hfl := range lines {
+		res[i] = lineFormatter(hfl)
+	}
+
+	return res
+}
+
+// splitRenderedLines splits a rendered hosts file in lines, whatever the line ending
+func splitRenderedLines(content string) []string {
+	lines := strings.Split(content, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+
+	return lines
+}
@@ -0,0 +1,173 @@
+package libhosty
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	mfs := NewMemFS()
+	if err := mfs.WriteFile("/etc/hosts", []byte("127.0.0.1 localhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	audit := NewAuditLog("", "alice")
+	audit.now = func() time.Time { return clock }
+
+	h, err := Open(context.Background(), "/etc/hosts", WithFS(mfs), WithDialect(DialectGlibc), WithHook(audit))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := h.AddHostsFileLine("10.0.0.1", "db", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.WriteHostsFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	clock = clock.Add(time.Hour)
+	audit.Actor = "bob"
+
+	if err := h.RemoveHostname("db"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.WriteHostsFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadAuditLogFS(mfs, "/etc/hosts"+auditLogSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %v", records)
+	}
+
+	first := records[0]
+	if first.Actor != "alice" || !first.Time.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected record %v", first)
+	}
+
+	if len(first.Operations) != 1 || first.Operations[0].Op != OpAdd.String() || first.Operations[0].Hostnames[0] != "db" {
+		t.Fatalf("unexpected operations %v", first.Operations)
+	}
+
+	if len(first.Before) != 2 || first.Before[0] != lineFormatter(h.HostsFileLines[0]) {
+		t.Fatalf("unexpected before lines %q", first.Before)
+	}
+
+	if got := QueryAuditRecords(records, AuditQuery{Actor: "bob", Hostname: "DB"}); len(got) != 1 || got[0].Operations[0].Op != OpRemove.String() {
+		t.Fatalf("unexpected query result %v", got)
+	}
+
+	if got := QueryAuditRecords(records, AuditQuery{Op: OpAdd.String()}); len(got) != 1 {
+		t.Fatalf("unexpected query result %v", got)
+	}
+
+	// the version written by alice
+	old, err := ReconstructAt(records, clock.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ip, err := old.LookupByHostname("db"); err != nil || ip.String() != "10.0.0.1" {
+		t.Fatalf("expected db in the reconstructed version, got %v %v", ip, err)
+	}
+
+	if _, err := ReconstructAt(records, clock.Add(-48*time.Hour)); err != ErrNoAuditRecord {
+		t.Fatalf("expected ErrNoAuditRecord, got %v", err)
+	}
+}
+
+func TestAuditLogShared(t *testing.T) {
+	mfs := NewMemFS()
+	audit := NewAuditLog("/var/log/hosts.audit.jsonl", "alice")
+
+	written := New(WithFS(mfs), WithHook(audit))
+	discarded := New(WithFS(mfs), WithHook(audit))
+
+	// a write without mutations records no operation
+	if err := written.WriteHostsFileTo("/etc/hosts"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := discarded.AddHostsFileLine("10.0.0.1", "db", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// pending mutations are held by the hosts file, not by the shared log
+	if len(discarded.auditPending[audit]) != 1 || len(written.auditPending) != 0 {
+		t.Fatalf("unexpected pending mutations %v %v", discarded.auditPending, written.auditPending)
+	}
+
+	records, err := ReadAuditLogFS(mfs, audit.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || len(records[0].Operations) != 0 {
+		t.Fatalf("expected a single record without operations, got %v", records)
+	}
+}
+
+func TestReadAuditLogMissing(t *testing.T) {
+	records, err := ReadAuditLogFS(NewMemFS(), "/missing")
+	if err != nil || len(records) != 0 {
+		t.Fatalf("expected no records, got %v %v", records, err)
+	}
+}
+
+func TestAuditLogRendering(t *testing.T) {
+	mfs := NewMemFS()
+	if err := mfs.WriteFile("/etc/hosts", []byte("127.0.0.1   localhost   # loopback\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	audit := NewAuditLog("", "alice")
+
+	h, err := Open(context.Background(), "/etc/hosts", WithFS(mfs), WithDialect(DialectWindows),
+		WithPreservation(PreserveRaw), WithIntegrity(nil), WithHook(audit))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first write only adds the integrity header
+	if err := h.WriteHostsFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := h.AddHostsFileLine("10.0.0.1", "db", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.WriteHostsFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadAuditLogFS(mfs, "/etc/hosts"+auditLogSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %v", records)
+	}
+
+	// the lines kept by the write are the same on both sides
+	rec := records[1]
+	if len(rec.Before) < 2 || len(rec.After) < 3 || rec.Before[1] != "127.0.0.1   localhost   # loopback" || rec.Before[1] != rec.After[1] {
+		t.Fatalf("unexpected before and after lines %q %q", rec.Before, rec.After)
+	}
+
+	if !strings.HasPrefix(rec.Before[0], "# "+integrityPrefix) || !strings.HasPrefix(rec.After[0], "# "+integrityPrefix) {
+		t.Fatalf("expected the integrity header on both sides, got %q %q", rec.Before, rec.After)
+	}
+}
@@ -0,0 +1,221 @@
+package libhosty
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// baselineVersion is the version of the baseline format
+const baselineVersion = 1
+
+// BaselineEntry holds a normalized hostname mapping recorded in a Baseline
+type BaselineEntry struct {
+	//Hostname is the mapped hostname, lower case and without trailing dot
+	Hostname string `json:"hostname"`
+
+	//Address is the address the hostname maps to, with its IPv6 zone if any
+	Address string `json:"address"`
+
+	//Comment is the comment of the line
+	Comment string `json:"comment,omitempty"`
+
+	//Annotations are the annotations of the line
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	//IsCommented is true if the line is commented out
+	IsCommented bool `json:"commented,omitempty"`
+}
+
+// canonical returns the entry as a single line, used to compute the baseline hash
+func (e BaselineEntry) canonical() string {
+	return e.Hostname + " " + e.Address + " " + strconv.FormatBool(e.IsCommented) + " " +
+		strconv.Quote(formatComment(HostsFileLine{Comment: e.Comment, Annotations: e.Annotations}))
+}
+
+// Baseline holds the normalized entries of a hosts file, recorded to detect drift
+type Baseline struct {
+	//Version is the baseline format version
+	Version int `json:"version"`
+
+	//Time is when the baseline has been recorded
+	Time time.Time `json:"time"`
+
+	//Path is the recorded hosts file path, if any
+	Path string `json:"path,omitempty"`
+
+	//Hash is the sha256 digest of the normalized entries, in the sha256:<hex> form
+	Hash string `json:"hash"`
+
+	//Entries are the normalized entries, sorted by hostname and address
+	Entries []BaselineEntry `json:"entries"`
+}
+
+// RecordBaseline returns a Baseline of the current entries of the hosts file
+func RecordBaseline(h *HostsFile) *Baseline {
+	h.Lock()
+	lines := cloneHostsFileLines(h.HostsFileLines)
+	path := h.Path
+	h.Unlock()
+
+	entries := baselineEntries(lines)
+
+	return &Baseline{
+		Version: baselineVersion,
+		Time:    time.Now().UTC(),
+		Path:    path,
+		Hash:    baselineHash(entries),
+		Entries: entries,
+	}
+}
+
+// ReadBaseline reads a Baseline stored as JSON at path.
+// error is not nil if something goes wrong
+func ReadBaseline(path string) (*Baseline, error) {
+	return ReadBaselineFS(OSFS{}, path)
+}
+
+// ReadBaselineFS reads a Baseline stored as JSON at path from the given FS.
+// error is ErrBaselineHashMismatch if the entries have been edited after recording,
+// not nil if something else goes wrong
+func ReadBaselineFS(fsys FS, path string) (*Baseline, error) {
+	data, err := fsys.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Baseline{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+
+	if b.Version != baselineVersion {
+		return nil, ErrUnsupportedBaseline(b.Version)
+	}
+
+	// CompareToBaseline relies on the hash, it must describe the entries
+	if b.Hash != baselineHash(b.Entries) {
+		return nil, ErrBaselineHashMismatch
+	}
+
+	return b, nil
+}
+
+// Save stores the baseline as JSON at path.
+// error is not nil if something goes wrong
+func (b *Baseline) Save(path string) error {
+	return b.SaveFS(OSFS{}, path)
+}
+
+// SaveFS stores the baseline as JSON at path on the given FS.
+// error is not nil if something goes wrong
+func (b *Baseline) SaveFS(fsys FS, path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return fsys.WriteFile(path, append(data, '\n'), defaultFileMode)
+}
+
+// HostsFileLines returns the baseline entries as address lines, one per entry.
+// error is not nil if an entry holds an invalid address
+func (b *Baseline) HostsFileLines() ([]HostsFileLine, error) {
+	lines := make([]HostsFileLine, 0, len(b.Entries))
+
+	for _, e := range b.Entries {
+		address, zone, _ := strings.Cut(e.Address, "%")
+
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return nil, ErrCannotParseIPAddress(e.Address)
+		}
+
+		hfl := HostsFileLine{
+			Type:        LineTypeAddress,
+			Address:     ip,
+			Zone:        zone,
+			Hostnames:   []string{e.Hostname},
+			Comment:     e.Comment,
+			Annotations: nilIfEmpty(e.Annotations),
+			IsCommented: e.IsCommented,
+		}
+		hfl.Raw = lineFormatter(hfl)
+
+		lines = append(lines, hfl)
+	}
+
+	return lines, nil
+}
+
+// CompareToBaseline returns the entry level changes from the baseline to the current hosts file.
+// formatting, line order and duplicated entries are ignored, no change means no drift.
+// error is not nil if the baseline holds an invalid address
+func CompareToBaseline(h *HostsFile, b *Baseline) ([]Change, error) {
+	current := RecordBaseline(h)
+	if current.Hash == b.Hash {
+		return []Change{}, nil
+	}
+
+	oldLines, err := b.HostsFileLines()
+	if err != nil {
+		return nil, err
+	}
+
+	newLines, err := current.HostsFileLines()
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffHostsFileLines(oldLines, newLines), nil
+}
+
+// baselineEntries returns the normalized, sorted and unique entries of lines
+func baselineEntries(lines []HostsFileLine) []BaselineEntry {
+	entries := make([]BaselineEntry, 0)
+	seen := make(map[string]bool)
+
+	for _, e := range HostsEntries(lines) {
+		address := e.Address.String()
+		if e.Zone != "" {
+			address += "%" + e.Zone
+		}
+
+		be := BaselineEntry{
+			Hostname:    normalizeHostname(e.Hostname),
+			Address:     address,
+			Comment:     e.Comment,
+			Annotations: e.Annotations,
+			IsCommented: e.IsCommented,
+		}
+
+		if seen[be.canonical()] {
+			continue
+		}
+
+		seen[be.canonical()] = true
+		entries = append(entries, be)
+	}
+
+	slices.SortFunc(entries, func(a, b BaselineEntry) int {
+		return strings.Compare(a.canonical(), b.canonical())
+	})
+
+	return entries
+}
+
+// baselineHash returns the digest of the given sorted entries
+func baselineHash(entries []BaselineEntry) string {
+	var sb strings.Builder
+
+	for _, e := range entries {
+		sb.WriteString(e.canonical())
+		sb.WriteByte('\n')
+	}
+
+	return digestOf([]byte(sb.String()))
+}
@@ -0,0 +1,124 @@
+package libhosty
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCompareToBaseline(t *testing.T) {
+	h, err := InitFromString("127.0.0.1 localhost\n10.0.0.1 db cache\n10.0.0.2 web\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mfs := NewMemFS()
+	if err := RecordBaseline(h).SaveFS(mfs, "/baseline.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	baseline, err := ReadBaselineFS(mfs, "/baseline.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// formatting, order and duplicates are not drift
+	same, err := InitFromString("10.0.0.2\tWEB.\n10.0.0.1   cache\n\n127.0.0.1 localhost\n10.0.0.1 db\n10.0.0.1 db\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changes, err := CompareToBaseline(same, baseline); err != nil || len(changes) != 0 {
+		t.Fatalf("expected no drift, got %v %v", changes, err)
+	}
+
+	if RecordBaseline(same).Hash != baseline.Hash {
+		t.Fatal("expected the same hash")
+	}
+
+	drifted, err := InitFromString("127.0.0.1 localhost\n10.0.0.9 db cache\n10.0.0.3 api\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := CompareToBaseline(drifted, baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := make(map[string]ChangeKind)
+	for _, c := range changes {
+		kinds[c.Hostname] = c.Kind
+	}
+
+	expected := map[string]ChangeKind{
+		"db":    ChangeChanged,
+		"cache": ChangeChanged,
+		"api":   ChangeAdded,
+		"web":   ChangeRemoved,
+	}
+
+	if len(kinds) != len(expected) {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+
+	for hn, kind := range expected {
+		if kinds[hn] != kind {
+			t.Fatalf("%s: expected %s, got %s", hn, kind, kinds[hn])
+		}
+	}
+}
+
+func TestReadBaselineUnsupported(t *testing.T) {
+	mfs := NewMemFS()
+	if err := mfs.WriteFile("/baseline.json", []byte(`{"version": 99}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadBaselineFS(mfs, "/baseline.json"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestReadBaselineTampered(t *testing.T) {
+	h, err := InitFromString("10.0.0.1 db\n10.0.0.2 cache\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mfs := NewMemFS()
+	b := RecordBaseline(h)
+	if err := b.SaveFS(mfs, "/baseline.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadBaselineFS(mfs, "/baseline.json"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// the entries are edited, the hash is left as recorded
+	b.Entries[0].Address = "10.6.6.6"
+	if err := b.SaveFS(mfs, "/baseline.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadBaselineFS(mfs, "/baseline.json"); !errors.Is(err, ErrBaselineHashMismatch) {
+		t.Fatalf("expected ErrBaselineHashMismatch, got %v", err)
+	}
+}
+
+func TestRecordBaselinePath(t *testing.T) {
+	mfs := NewMemFS()
+	if err := mfs.WriteFile("/etc/hosts", []byte("10.0.0.1 db\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := Open(context.Background(), "/etc/hosts", WithFS(mfs))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b := RecordBaseline(h); b.Path != "/etc/hosts" || len(b.Entries) != 1 || b.Entries[0].Address != "10.0.0.1" {
+		t.Fatalf("unexpected baseline %v", b)
+	}
+}
@@ -0,0 +1,142 @@
+// Command hostsdrift records a baseline of a hosts file and checks it for drift.
+//
+// Usage:
+//
+//	hostsdrift record [-hosts path] [-dialect name] baseline.json
+//	hostsdrift check [-hosts path] [-dialect name] baseline.json
+//
+// check prints one line per change and exits with status 0 if the hosts file
+// matches the baseline, 1 if it drifted and 2 on errors, so it can be run from cron
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/areYouLazy/libhosty/v2"
+)
+
+const (
+	exitOK    = 0
+	exitDrift = 1
+	exitError = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command with the given arguments and returns the exit status
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitError
+	}
+
+	cmd := args[0]
+	if cmd != "record" && cmd != "check" {
+		usage(stderr)
+		return exitError
+	}
+
+	fs := flag.NewFlagSet("hostsdrift "+cmd, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	hostsPath := fs.String("hosts", "", "hosts file path, defaults to the dialect default path")
+	dialectName := fs.String("dialect", "", "hosts file dialect, defaults to the current OS one")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitError
+	}
+
+	if fs.NArg() != 1 {
+		usage(stderr)
+		return exitError
+	}
+
+	baselinePath := fs.Arg(0)
+
+	h, err := open(*hostsPath, *dialectName)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if cmd == "record" {
+		if err := libhosty.RecordBaseline(h).Save(baselinePath); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+
+		return exitOK
+	}
+
+	baseline, err := libhosty.ReadBaseline(baselinePath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	changes, err := libhosty.CompareToBaseline(h, baseline)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	for _, c := range changes {
+		fmt.Fprintln(stdout, formatChange(c))
+	}
+
+	if len(changes) > 0 {
+		return exitDrift
+	}
+
+	return exitOK
+}
+
+// open loads the hosts file at path for the named dialect, empty values are defaulted
+func open(path, dialectName string) (*libhosty.HostsFile, error) {
+	d := libhosty.DefaultDialect()
+
+	if dialectName != "" {
+		var err error
+		if d, err = libhosty.DialectByName(dialectName); err != nil {
+			return nil, err
+		}
+	}
+
+	if path == "" {
+		path = d.HostsFilePath
+	}
+
+	return libhosty.Open(context.Background(), path, libhosty.WithDialect(d))
+}
+
+// formatChange returns a single line description of c
+func formatChange(c libhosty.Change) string {
+	switch {
+	case c.Old == nil:
+		return fmt.Sprintf("%s %s %s", c.Kind, c.Hostname, entryAddress(c.New))
+	case c.New == nil:
+		return fmt.Sprintf("%s %s %s", c.Kind, c.Hostname, entryAddress(c.Old))
+	default:
+		return fmt.Sprintf("%s %s %s -> %s", c.Kind, c.Hostname, entryAddress(c.Old), entryAddress(c.New))
+	}
+}
+
+// entryAddress returns the address of e, with its zone if any
+func entryAddress(e *libhosty.HostsEntry) string {
+	if e.Zone != "" {
+		return e.Address.String() + "%" + e.Zone
+	}
+
+	return e.Address.String()
+}
+
+// usage prints the command usage
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: hostsdrift record|check [-hosts path] [-dialect name] baseline.json")
+}
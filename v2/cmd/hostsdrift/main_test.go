@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	hosts := filepath.Join(dir, "hosts")
+	baseline := filepath.Join(dir, "baseline.json")
+
+	if err := os.WriteFile(hosts, []byte("127.0.0.1 localhost\n10.0.0.1 db\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	if code := run([]string{"record", "-hosts", hosts, "-dialect", "glibc", baseline}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("record: expected %d, got %d: %s", exitOK, code, stderr.String())
+	}
+
+	if code := run([]string{"check", "-hosts", hosts, "-dialect", "glibc", baseline}, &stdout, &stderr); code != exitOK || stdout.Len() != 0 {
+		t.Fatalf("check: expected no drift, got %d: %s%s", code, stdout.String(), stderr.String())
+	}
+
+	if err := os.WriteFile(hosts, []byte("127.0.0.1 localhost\n10.6.6.6 db\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := run([]string{"check", "-hosts", hosts, "-dialect", "glibc", baseline}, &stdout, &stderr); code != exitDrift {
+		t.Fatalf("check: expected drift, got %d: %s", code, stderr.String())
+	}
+
+	if got := strings.TrimSpace(stdout.String()); got != "change-changed db 10.0.0.1 -> 10.6.6.6" {
+		t.Fatalf("unexpected output %q", got)
+	}
+
+	if code := run([]string{"unknown", baseline}, &stdout, &stderr); code != exitError {
+		t.Fatalf("expected %d, got %d", exitError, code)
+	}
+}
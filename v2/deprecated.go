@@ -5,25 +5,25 @@ import "os"
 // RestoreDefaultWindowsHostsFile loads the default windows hosts file
 func (h *HostsFile) RestoreDefaultWindowsHostsFile() {
 	hfl, _ := ParseHostsFileFromString(windowsHostsTemplate)
-	h.HostsFileLines = hfl
+	h.replaceLines(h.snapshot(), hfl)
 }
 
 // RestoreDefaultLinuxHostsFile loads the default linux hosts file
 func (h *HostsFile) RestoreDefaultLinuxHostsFile() {
 	hfl, _ := ParseHostsFileFromString(linuxHostsTemplate)
-	h.HostsFileLines = hfl
+	h.replaceLines(h.snapshot(), hfl)
 }
 
 // RestoreDefaultDarwinHostsFile loads the default darwin hosts file
 func (h *HostsFile) RestoreDefaultDarwinHostsFile() {
 	hfl, _ := ParseHostsFileFromString(darwinHostsTemplate)
-	h.HostsFileLines = hfl
+	h.replaceLines(h.snapshot(), hfl)
 }
 
 // AddDockerDesktopTemplate adds the dockerDesktopTemplate to the actual hostsFile
 func (h *HostsFile) AddDockerDesktopTemplate() {
 	hfl, _ := ParseHostsFileFromString(dockerDesktopTemplate)
-	h.HostsFileLines = append(h.HostsFileLines, hfl...)
+	h.insertLines(len(h.HostsFileLines), hfl...)
 }
 
 // SaveHostsFile write hosts file to configured path.
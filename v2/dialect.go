@@ -0,0 +1,199 @@
+package libhosty
+
+import (
+	"net"
+	"runtime"
+	"strings"
+)
+
+// Dialect describes how a given resolver reads the hosts file.
+// It drives parsing, validation, rendering and templates,
+// so that a hosts file can be edited for a platform other than the running one
+type Dialect struct {
+	//Name is the dialect name, as used by DialectByName
+	Name string
+
+	//HostsFilePath is the default hosts file location
+	HostsFilePath string
+
+	//MaxHostnamesPerLine is the maximum number of hostnames on a single address line
+	MaxHostnamesPerLine int
+
+	//MaxLineLength is the maximum length of an address line, 0 means unlimited
+	MaxLineLength int
+
+	//SupportsZones is true if IPv6 zones (fe80::1%lo0) are accepted
+	SupportsZones bool
+
+	//MultipleMatches is true if every matching line is returned (multi on),
+	//false if the resolver stops at the first match
+	MultipleMatches bool
+
+	//CRLF is true if lines are terminated by \r\n
+	CRLF bool
+
+	//Template is the default hosts file content
+	Template string
+}
+
+var (
+	// DialectGlibc defines the glibc files backend, with the default host.conf (multi off)
+	DialectGlibc = Dialect{
+		Name:                "glibc",
+		HostsFilePath:       unixFilePath + hostsFileName,
+		MaxHostnamesPerLine: DefaultMaxHostnamesPerLine,
+		SupportsZones:       true,
+		Template:            linuxHostsTemplate,
+	}
+
+	// DialectMusl defines the musl libc resolver, which reads lines in a 512 bytes buffer
+	DialectMusl = Dialect{
+		Name:                "musl",
+		HostsFilePath:       unixFilePath + hostsFileName,
+		MaxHostnamesPerLine: DefaultMaxHostnamesPerLine,
+		MaxLineLength:       511,
+		SupportsZones:       true,
+		MultipleMatches:     true,
+		Template:            linuxHostsTemplate,
+	}
+
+	// DialectDarwin defines the macOS resolver
+	DialectDarwin = Dialect{
+		Name:                "darwin",
+		HostsFilePath:       unixFilePath + hostsFileName,
+		MaxHostnamesPerLine: DefaultMaxHostnamesPerLine,
+		SupportsZones:       true,
+		MultipleMatches:     true,
+		Template:            darwinHostsTemplate,
+	}
+
+	// DialectWindows defines the windows resolver, which ignores hostnames after the 9th
+	DialectWindows = Dialect{
+		Name:                "windows",
+		HostsFilePath:       windowsFilePath + hostsFileName,
+		MaxHostnamesPerLine: 9,
+		SupportsZones:       false,
+		CRLF:                true,
+		Template:            windowsHostsTemplate,
+	}
+
+	// DialectBusyBox defines the BusyBox/uClibc resolver
+	DialectBusyBox = Dialect{
+		Name:                "busybox",
+		HostsFilePath:       unixFilePath + hostsFileName,
+		MaxHostnamesPerLine: DefaultMaxHostnamesPerLine,
+		SupportsZones:       false,
+		Template:            linuxHostsTemplate,
+	}
+
+	// DialectAndroid defines the Android bionic resolver
+	DialectAndroid = Dialect{
+		Name:                "android",
+		HostsFilePath:       androidFilePath + hostsFileName,
+		MaxHostnamesPerLine: DefaultMaxHostnamesPerLine,
+		SupportsZones:       false,
+		Template:            androidHostsTemplate,
+	}
+)
+
+// Dialects returns every known dialect
+func Dialects() []Dialect {
+	return []Dialect{
+		DialectGlibc,
+		DialectMusl,
+		DialectDarwin,
+		DialectWindows,
+		DialectBusyBox,
+		DialectAndroid,
+	}
+}
+
+// DialectByName returns the dialect with the given name.
+// error is not nil if the dialect is unknown
+func DialectByName(name string) (Dialect, error) {
+	for _, d := range Dialects() {
+		if strings.EqualFold(d.Name, name) {
+			return d, nil
+		}
+	}
+
+	return Dialect{}, ErrUnknownDialect(name)
+}
+
+// DialectForOS returns the dialect used by the given runtime.GOOS value
+func DialectForOS(goos string) Dialect {
+	switch goos {
+	case "windows":
+		return DialectWindows
+	case "darwin", "ios":
+		return DialectDarwin
+	case "android":
+		return DialectAndroid
+	default:
+		return DialectGlibc
+	}
+}
+
+// DefaultDialect returns the dialect of the running system based on runtime.GOOS result
+func DefaultDialect() Dialect {
+	return DialectForOS(runtime.GOOS)
+}
+
+// lineEnding returns the line terminator for the dialect
+func (d Dialect) lineEnding() string {
+	if d.CRLF {
+		return "\r\n"
+	}
+
+	return "\n"
+}
+
+// parseAddress parses the given address, with an optional IPv6 zone if the dialect supports them.
+// ip is nil if the address cannot be parsed
+func (d Dialect) parseAddress(address string) (ip net.IP, zone string) {
+	host, zone, hasZone := strings.Cut(address, "%")
+
+	if !hasZone {
+		return net.ParseIP(address), ""
+	}
+
+	// zones are only allowed on IPv6 addresses
+	ip = net.ParseIP(host)
+	if !d.SupportsZones || zone == "" || ip == nil || ip.To4() != nil {
+		return nil, ""
+	}
+
+	return ip, zone
+}
+
+// dialect returns the dialect configured on the HostsFile, or the system one if none is configured
+func (h *HostsFile) dialect() Dialect {
+	if h.Dialect.Name == "" {
+		return DefaultDialect()
+	}
+
+	return h.Dialect
+}
+
+// parseAddress parses the given address according to the configured dialect.
+// error is not nil if the address cannot be parsed or is not supported by the dialect
+func (h *HostsFile) parseAddress(address string) (net.IP, string, error) {
+	d := h.dialect()
+
+	ip, zone := d.parseAddress(address)
+	if ip != nil {
+		return ip, zone, nil
+	}
+
+	// a valid address with a zone the dialect does not support
+	if host, _, ok := strings.Cut(address, "%"); ok && net.ParseIP(host) != nil {
+		return nil, "", ErrUnsupportedAddress(address, d.Name)
+	}
+
+	return nil, "", ErrCannotParseIPAddress(address)
+}
+
+// sameAddress reports whether the given line holds ip with the given zone
+func sameAddress(hfl HostsFileLine, ip net.IP, zone string) bool {
+	return net.IP.Equal(hfl.Address, ip) && hfl.Zone == zone
+}
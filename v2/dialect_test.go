@@ -0,0 +1,80 @@
+package libhosty
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDialectByName(t *testing.T) {
+	for _, d := range Dialects() {
+		got, err := DialectByName(strings.ToUpper(d.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got.Name != d.Name {
+			t.Fatalf("wants %q got %q", d.Name, got.Name)
+		}
+	}
+
+	if _, err := DialectByName("plan9"); err == nil {
+		t.Fatal("should fail with unknown dialect")
+	}
+}
+
+func TestDialectZones(t *testing.T) {
+	h := &HostsFile{Dialect: DialectDarwin}
+
+	hfl, err := parser([]byte(darwinHostsTemplate), h.parserConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.HostsFileLines = hfl
+
+	res := h.GetHostsFileLinesByAddress("fe80::1")
+	if len(res) != 1 || res[0].Zone != "lo0" {
+		t.Fatalf("wants zoned fe80::1 line got %v", res)
+	}
+
+	if l := lineFormatter(*res[0]); !strings.HasPrefix(l, "fe80::1%lo0") {
+		t.Fatalf("zone missing from rendered line %q", l)
+	}
+
+	// windows does not support zones
+	h = &HostsFile{Dialect: DialectWindows}
+	if _, _, err := h.AddHostsFileLine("fe80::1%eth0", "zoned.local", ""); err == nil {
+		t.Fatal("should fail with zoned address")
+	}
+
+	hfl, err = parser([]byte("fe80::1%lo0 localhost"), h.parserConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hfl[0].Type != LineTypeUnknown {
+		t.Fatalf("wants %s got %s", LineTypeUnknown, hfl[0].Type)
+	}
+}
+
+func TestDialectRender(t *testing.T) {
+	h := &HostsFile{Dialect: DialectWindows}
+	h.AddCommentFileLine("managed")
+	h.AddHostsFileLine("10.0.0.1", "a.local", "")
+
+	if got := h.RenderHostsFile(); got != "# managed\r\n10.0.0.1         a.local" {
+		t.Fatalf("unexpected rendering %q", got)
+	}
+}
+
+func TestDialectTemplate(t *testing.T) {
+	h := &HostsFile{Dialect: DialectAndroid}
+
+	if !h.RestoreTemplate() {
+		t.Fatal("unable to restore template")
+	}
+
+	if len(h.GetHostsFileLinesByHostname("ip6-localhost")) != 1 {
+		t.Fatal("android template should map ip6-localhost")
+	}
+}
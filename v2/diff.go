@@ -0,0 +1,231 @@
+package libhosty
+
+import (
+	"net"
+	"strconv"
+)
+
+// ChangeKind define a safe type for the kind of change of a hosts entry
+type ChangeKind int
+
+const (
+	//ChangeAdded a new hostname mapping has been added
+	ChangeAdded ChangeKind = iota
+
+	//ChangeRemoved a hostname mapping has been removed
+	ChangeRemoved
+
+	//ChangeChanged a hostname now maps to a different address, or its comment or annotations changed
+	ChangeChanged
+
+	//ChangeCommented a hostname mapping has been commented out
+	ChangeCommented
+
+	//ChangeUncommented a hostname mapping has been uncommented
+	ChangeUncommented
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "change-added"
+	case ChangeRemoved:
+		return "change-removed"
+	case ChangeChanged:
+		return "change-changed"
+	case ChangeCommented:
+		return "change-commented"
+	case ChangeUncommented:
+		return "change-uncommented"
+	default:
+		return "change-unknown"
+	}
+}
+
+// HostsEntry holds a single hostname mapping of an address line
+type HostsEntry struct {
+	//Row is the index of the line holding the entry
+	Row int
+
+	//Hostname is the mapped hostname
+	Hostname string
+
+	//Address is the address the hostname maps to
+	Address net.IP
+
+	//Zone is the IPv6 zone of the address, if any
+	Zone string
+
+	//Comment is the comment of the line
+	Comment string
+
+	//Annotations are the annotations of the line
+	Annotations map[string]string
+
+	//IsCommented is true if the line is commented out
+	IsCommented bool
+}
+
+// key identifies the entry regardless of its position, comment and annotations
+func (e HostsEntry) key() string {
+	return normalizeHostname(e.Hostname) + " " + e.Address.String() + "%" + e.Zone + " " + strconv.FormatBool(e.IsCommented)
+}
+
+// Change holds a change of a hosts entry between two versions of a hosts file
+type Change struct {
+	//Kind is the kind of change
+	Kind ChangeKind
+
+	//Hostname is the changed hostname
+	Hostname string
+
+	//Old is the entry before the change, nil for ChangeAdded
+	Old *HostsEntry
+
+	//New is the entry after the change, nil for ChangeRemoved
+	New *HostsEntry
+}
+
+// HostsEntries returns every hostname mapping of the given lines, in file order
+func HostsEntries(lines []HostsFileLine) []HostsEntry {
+	entries := make([]HostsEntry, 0)
+
+	for idx, hfl := range lines {
+		if hfl.Type != LineTypeAddress {
+			continue
+		}
+
+		for _, hn := range hfl.Hostnames {
+			entries = append(entries, HostsEntry{
+				Row:         idx,
+				Hostname:    hn,
+				Address:     hfl.Address,
+				Zone:        hfl.Zone,
+				Comment:     hfl.Comment,
+				Annotations: hfl.Annotations,
+				IsCommented: hfl.IsCommented,
+			})
+		}
+	}
+
+	return entries
+}
+
+// DiffHostsFileLines returns the entry level changes from oldLines to newLines.
+// changes follow the order of newLines, removed entries come last.
+// formatting, line order and moves between lines are not reported
+func DiffHostsFileLines(oldLines, newLines []HostsFileLine) []Change {
+	oldEntries := uniqueHostsEntries(HostsEntries(oldLines))
+	newEntries := uniqueHostsEntries(HostsEntries(newLines))
+
+	oldByKey := make(map[string]HostsEntry)
+	for _, e := range oldEntries {
+		oldByKey[e.key()] = e
+	}
+
+	newByKey := make(map[string]HostsEntry)
+	for _, e := range newEntries {
+		newByKey[e.key()] = e
+	}
+
+	// removed entries, by hostname, to pair them with added ones
+	removed := make(map[string][]HostsEntry)
+	for _, e := range oldEntries {
+		if _, ok := newByKey[e.key()]; !ok {
+			hn := normalizeHostname(e.Hostname)
+			removed[hn] = append(removed[hn], e)
+		}
+	}
+
+	changes := make([]Change, 0)
+
+	for _, e := range newEntries {
+		e := e
+		hn := normalizeHostname(e.Hostname)
+
+		if old, ok := oldByKey[e.key()]; ok {
+			if old.Comment != e.Comment || !equalAnnotations(old.Annotations, e.Annotations) {
+				changes = append(changes, Change{Kind: ChangeChanged, Hostname: hn, Old: &old, New: &e})
+			}
+
+			continue
+		}
+
+		old, ok := pairRemovedEntry(removed, e)
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeAdded, Hostname: hn, New: &e})
+			continue
+		}
+
+		kind := ChangeChanged
+		if net.IP.Equal(old.Address, e.Address) && old.Zone == e.Zone {
+			kind = ChangeUncommented
+			if e.IsCommented {
+				kind = ChangeCommented
+			}
+		}
+
+		changes = append(changes, Change{Kind: kind, Hostname: hn, Old: &old, New: &e})
+	}
+
+	// unpaired removed entries, in file order
+	for _, e := range oldEntries {
+		e := e
+		hn := normalizeHostname(e.Hostname)
+
+		for _, r := range removed[hn] {
+			if r.key() == e.key() {
+				changes = append(changes, Change{Kind: ChangeRemoved, Hostname: hn, Old: &e})
+				break
+			}
+		}
+	}
+
+	return changes
+}
+
+// pairRemovedEntry returns the removed entry replaced by e, preferring the same address
+// (a commented or uncommented entry), then an entry with the same commented state
+// (an address change), and drops it from removed
+func pairRemovedEntry(removed map[string][]HostsEntry, e HostsEntry) (HostsEntry, bool) {
+	hn := normalizeHostname(e.Hostname)
+	candidates := removed[hn]
+
+	match := -1
+	for i, r := range candidates {
+		if net.IP.Equal(r.Address, e.Address) && r.Zone == e.Zone {
+			match = i
+			break
+		}
+
+		if match < 0 && r.IsCommented == e.IsCommented {
+			match = i
+		}
+	}
+
+	if match < 0 {
+		return HostsEntry{}, false
+	}
+
+	old := candidates[match]
+	removed[hn] = append(candidates[:match:match], candidates[match+1:]...)
+
+	return old, true
+}
+
+// uniqueHostsEntries returns entries without duplicates, keeping the first occurrence
+func uniqueHostsEntries(entries []HostsEntry) []HostsEntry {
+	seen := make(map[string]bool)
+	res := make([]HostsEntry, 0, len(entries))
+
+	for _, e := range entries {
+		if seen[e.key()] {
+			continue
+		}
+
+		seen[e.key()] = true
+		res = append(res, e)
+	}
+
+	return res
+}
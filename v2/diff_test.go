@@ -0,0 +1,52 @@
+package libhosty
+
+import "testing"
+
+func TestDiffHostsFileLines(t *testing.T) {
+	oldLines, err := ParseHostsFileFromString("127.0.0.1 localhost\n10.0.0.1 db cache\n10.0.0.2 web\n10.0.0.3 old\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newLines, err := ParseHostsFileFromString("127.0.0.1 localhost\n10.0.0.9 db\n10.0.0.1 cache # moved\n# 10.0.0.2 web\n10.0.0.4 new\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := DiffHostsFileLines(oldLines, newLines)
+
+	expected := []struct {
+		kind     ChangeKind
+		hostname string
+	}{
+		{ChangeChanged, "db"},
+		{ChangeChanged, "cache"},
+		{ChangeCommented, "web"},
+		{ChangeAdded, "new"},
+		{ChangeRemoved, "old"},
+	}
+
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %v", len(expected), changes)
+	}
+
+	for i, e := range expected {
+		if changes[i].Kind != e.kind || changes[i].Hostname != e.hostname {
+			t.Fatalf("change %d: expected %s %s, got %s %s", i, e.kind, e.hostname, changes[i].Kind, changes[i].Hostname)
+		}
+	}
+
+	if changes[0].Old.Address.String() != "10.0.0.1" || changes[0].New.Address.String() != "10.0.0.9" {
+		t.Fatalf("unexpected addresses %v %v", changes[0].Old, changes[0].New)
+	}
+
+	// back to the previous version
+	changes = DiffHostsFileLines(newLines, oldLines)
+	if len(changes) != 5 || changes[2].Kind != ChangeUncommented {
+		t.Fatalf("unexpected reverse changes %v", changes)
+	}
+
+	if changes := DiffHostsFileLines(oldLines, oldLines); len(changes) != 0 {
+		t.Fatalf("expected no changes, got %v", changes)
+	}
+}
@@ -0,0 +1,347 @@
+package libhosty
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	//AnnotationDisabled is the annotation holding when an entry has been disabled
+	AnnotationDisabled = "disabled"
+
+	//AnnotationDisabledBy is the annotation holding who disabled an entry, see escapeAnnotation
+	AnnotationDisabledBy = "disabled-by"
+
+	//AnnotationDisabledReason is the annotation holding why an entry has been disabled, see escapeAnnotation
+	AnnotationDisabledReason = "disabled-reason"
+
+	//AnnotationDisabledUntil is the annotation holding when an entry is due to be re-enabled
+	AnnotationDisabledUntil = "disabled-until"
+)
+
+// disableAnnotations are the annotations set by Disable
+var disableAnnotations = []string{
+	AnnotationDisabled,
+	AnnotationDisabledBy,
+	AnnotationDisabledReason,
+	AnnotationDisabledUntil,
+}
+
+// DisableOptions holds options for Disable
+type DisableOptions struct {
+	//Reason is why the entries are disabled
+	Reason string
+
+	//By is who disabled the entries, empty means the current user
+	By string
+
+	//Until is when the entries are due to be re-enabled by ReenableDue, zero means never
+	Until time.Time
+}
+
+// DisableInfo holds the disable metadata of an entry
+type DisableInfo struct {
+	//At is when the entry has been disabled
+	At time.Time
+
+	//By is who disabled the entry
+	By string
+
+	//Reason is why the entry has been disabled
+	Reason string
+
+	//Until is when the entry is due to be re-enabled, zero means never
+	Until time.Time
+}
+
+// Disable comments out every active address line matching the given Selector,
+// recording when, by whom and why in the line annotations.
+// error is not nil if a hook vetoes the change, no line is disabled in that case
+func (h *HostsFile) Disable(sel Selector, opts DisableOptions) error {
+	by := opts.By
+	if by == "" {
+		by = currentUser()
+	}
+
+	annotations := map[string]string{
+		AnnotationDisabled:   formatAnnotationTime(time.Now()),
+		AnnotationDisabledBy: escapeAnnotation(by),
+	}
+
+	if opts.Reason != "" {
+		annotations[AnnotationDisabledReason] = escapeAnnotation(opts.Reason)
+	}
+
+	if !opts.Until.IsZero() {
+		annotations[AnnotationDisabledUntil] = formatAnnotationTime(opts.Until)
+	}
+
+	rows, old := h.selectAddressRows(sel, false)
+
+	lines := cloneHostsFileLines(old)
+	for i := range lines {
+		lines[i].IsCommented = true
+		lines[i].Annotations = mergeAnnotations(lines[i].Annotations, annotations)
+	}
+
+	return h.updateLines(OpComment, rows, old, lines)
+}
+
+// Enable uncomments every address line matching the given Selector disabled by Disable,
+// dropping its disable metadata.
+// error is not nil if a hook vetoes the change, no line is enabled in that case
+func (h *HostsFile) Enable(sel Selector) error {
+	return h.enableRows(h.selectAddressRows(func(row int, hfl *HostsFileLine) bool {
+		_, disabled := hfl.Annotations[AnnotationDisabled]
+		return disabled && sel(row, hfl)
+	}, true))
+}
+
+// ReenableDue uncomments every address line disabled by Disable whose Until is at or before now,
+// dropping its disable metadata. hostnames mapped elsewhere in the meantime are handled
+// with the ReplaceExisting policy, see ReenableDueWithPolicy. it returns the re-enabled rows.
+// error is not nil if a hook vetoes the change, no line is enabled in that case
+func (h *HostsFile) ReenableDue(now time.Time) ([]int, error) {
+	enabled, _, err := h.ReenableDueWithPolicy(now, ReplaceExisting)
+
+	return enabled, err
+}
+
+// ReenableDueWithPolicy is ReenableDue with the given policy for the hostnames of a due line
+// that are mapped to a different address by an uncommented line:
+// ReplaceExisting removes them from the other lines, ErrorOnConflict returns a *HostnameConflictError,
+// KeepExisting leaves the due line disabled and AllowMultiple re-enables it anyway.
+// due lines sharing a hostname are not re-enabled together, the most recently disabled one wins
+// and the others stay disabled.
+// it returns the re-enabled rows and the rows, as they were before the call, removed because
+// ReplaceExisting left them without hostnames.
+// error is not nil if a hook vetoes the change, no line is enabled in that case
+func (h *HostsFile) ReenableDueWithPolicy(now time.Time, conflict ConflictPolicy) (enabled, removed []int, err error) {
+	current := h.snapshot()
+
+	// due lines, most recently disabled first, the last row first on ties
+	due := make([]int, 0)
+	at := make(map[int]time.Time)
+
+	for idx, hfl := range current {
+		info, ok := DisabledInfo(hfl)
+		if !ok || info.Until.IsZero() || now.Before(info.Until) {
+			continue
+		}
+
+		due = append(due, idx)
+		at[idx] = info.At
+	}
+
+	slices.SortStableFunc(due, func(a, b int) int {
+		if c := at[b].Compare(at[a]); c != 0 {
+			return c
+		}
+
+		return b - a
+	})
+
+	claimed := make(map[string]bool)
+	enable := make(map[int]bool)
+	strip := make(map[int][]string)
+
+	for _, idx := range due {
+		hfl := current[idx]
+
+		if slices.ContainsFunc(hfl.Hostnames, func(hn string) bool { return claimed[normalizeHostname(hn)] }) {
+			continue
+		}
+
+		if conflict != AllowMultiple {
+			if row, hostname := conflictingRow(current, idx); row >= 0 {
+				switch conflict {
+				case ErrorOnConflict:
+					return nil, nil, &HostnameConflictError{Hostname: hostname, Row: row, Line: current[row]}
+				case KeepExisting:
+					continue
+				}
+			}
+
+			// remove the hostnames from the other addresses
+			for row, other := range current {
+				for _, hn := range hfl.Hostnames {
+					if isActiveMapping(other, hn) && !sameAddress(other, hfl.Address, hfl.Zone) {
+						strip[row] = append(strip[row], hn)
+					}
+				}
+			}
+		}
+
+		for _, hn := range hfl.Hostnames {
+			claimed[normalizeHostname(hn)] = true
+		}
+
+		enable[idx] = true
+	}
+
+	if len(strip) == 0 {
+		rows := make([]int, 0, len(enable))
+		for idx := range current {
+			if enable[idx] {
+				rows = append(rows, idx)
+			}
+		}
+
+		old := make([]HostsFileLine, len(rows))
+		for i, row := range rows {
+			old[i] = current[row]
+		}
+
+		if err := h.enableRows(rows, old); err != nil {
+			return nil, nil, err
+		}
+
+		return rows, []int{}, nil
+	}
+
+	lines := make([]HostsFileLine, 0, len(current))
+	enabled, removed = make([]int, 0), make([]int, 0)
+
+	for idx, hfl := range cloneHostsFileLines(current) {
+		switch {
+		case enable[idx]:
+			hfl = enabledLine(hfl)
+			enabled = append(enabled, len(lines))
+		case len(strip[idx]) > 0:
+			for _, hn := range strip[idx] {
+				hfl.Hostnames, _ = withoutHostname(hfl.Hostnames, hn)
+			}
+
+			if len(hfl.Hostnames) == 0 {
+				removed = append(removed, idx)
+				continue
+			}
+		default:
+			lines = append(lines, hfl)
+			continue
+		}
+
+		hfl.Raw = lineFormatter(hfl)
+		lines = append(lines, hfl)
+	}
+
+	if err := h.replaceLines(current, lines); err != nil {
+		return nil, nil, err
+	}
+
+	return enabled, removed, nil
+}
+
+// DisabledInfo returns the disable metadata of the given line, ok is false if it has not been disabled by Disable
+func DisabledInfo(hfl HostsFileLine) (info DisableInfo, ok bool) {
+	at, ok := hfl.Annotations[AnnotationDisabled]
+	if !ok || !hfl.IsCommented {
+		return DisableInfo{}, false
+	}
+
+	info.At, _ = time.Parse(time.RFC3339, at)
+	info.By = unescapeAnnotation(hfl.Annotations[AnnotationDisabledBy])
+	info.Reason = unescapeAnnotation(hfl.Annotations[AnnotationDisabledReason])
+
+	if until, ok := hfl.Annotations[AnnotationDisabledUntil]; ok {
+		info.Until, _ = time.Parse(time.RFC3339, until)
+	}
+
+	return info, true
+}
+
+// enableRows uncomments the given rows, dropping their disable metadata.
+// old are the lines expected at those rows
+func (h *HostsFile) enableRows(rows []int, old []HostsFileLine) error {
+	lines := cloneHostsFileLines(old)
+	for i := range lines {
+		lines[i] = enabledLine(lines[i])
+	}
+
+	return h.updateLines(OpUncomment, rows, old, lines)
+}
+
+// enabledLine returns hfl uncommented, without its disable metadata
+func enabledLine(hfl HostsFileLine) HostsFileLine {
+	hfl.IsCommented = false
+	hfl.Annotations = cloneAnnotations(hfl.Annotations)
+
+	for _, k := range disableAnnotations {
+		delete(hfl.Annotations, k)
+	}
+
+	hfl.Annotations = nilIfEmpty(hfl.Annotations)
+
+	return hfl
+}
+
+// conflictingRow returns the first uncommented row mapping a hostname of the given row
+// to a different address, and the hostname. row is -1 if there is none
+func conflictingRow(lines []HostsFileLine, row int) (int, string) {
+	for idx, hfl := range lines {
+		if idx == row || sameAddress(hfl, lines[row].Address, lines[row].Zone) {
+			continue
+		}
+
+		for _, hostname := range lines[row].Hostnames {
+			if isActiveMapping(hfl, hostname) {
+				return idx, hostname
+			}
+		}
+	}
+
+	return -1, ""
+}
+
+// isActiveMapping reports whether hfl is an uncommented address line mapping hostname
+func isActiveMapping(hfl HostsFileLine, hostname string) bool {
+	if hfl.Type != LineTypeAddress || hfl.IsCommented {
+		return false
+	}
+
+	hostname = normalizeHostname(hostname)
+	for _, hn := range hfl.Hostnames {
+		if normalizeHostname(hn) == hostname {
+			return true
+		}
+	}
+
+	return false
+}
+
+// escapeAnnotation returns s in a readable form that can be stored as an annotation value:
+// spaces become underscores, underscores and the characters annotations cannot hold are %XX escaped
+func escapeAnnotation(s string) string {
+	var sb strings.Builder
+
+	for _, r := range s {
+		switch {
+		case r == ' ':
+			sb.WriteByte('_')
+		case r == '_' || r == '%' || r == '#' || unicode.IsSpace(r) || unicode.IsControl(r):
+			for _, b := range []byte(string(r)) {
+				fmt.Fprintf(&sb, "%%%02X", b)
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
+// unescapeAnnotation reverts escapeAnnotation, values that cannot be unescaped are returned as they are
+func unescapeAnnotation(s string) string {
+	s = strings.ReplaceAll(s, "_", " ")
+
+	if res, err := url.PathUnescape(s); err == nil {
+		return res
+	}
+
+	return s
+}
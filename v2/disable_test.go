@@ -0,0 +1,195 @@
+package libhosty
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestDisable(t *testing.T) {
+	h, err := InitFromString("10.0.0.1 db # primary db\n10.0.0.2 web\n10.0.0.3 cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	until := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	opts := DisableOptions{Reason: "maintenance window #42", By: "alice", Until: until}
+	if err := h.Disable(SelectRegexp(`^(db|web)$`), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Disable(SelectHostname("cache"), DisableOptions{By: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reparsed, err := InitFromString(h.RenderHostsFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hfl := reparsed.HostsFileLines[0]
+	if !hfl.IsCommented || hfl.Comment != "primary db" {
+		t.Fatalf("unexpected line %v", hfl)
+	}
+
+	info, ok := DisabledInfo(hfl)
+	if !ok || info.Reason != "maintenance window #42" || info.By != "alice" || !info.Until.Equal(until) || info.At.IsZero() {
+		t.Fatalf("unexpected disable info %v %v", info, ok)
+	}
+
+	rows, err := reparsed.ReenableDue(until.Add(-time.Minute))
+	if err != nil || len(rows) != 0 {
+		t.Fatalf("expected nothing to re-enable, got %v %v", rows, err)
+	}
+
+	rows, err = reparsed.ReenableDue(until)
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("expected 2 rows to be re-enabled, got %v %v", rows, err)
+	}
+
+	hfl = reparsed.HostsFileLines[0]
+	if hfl.IsCommented || hfl.Annotations != nil || hfl.Comment != "primary db" {
+		t.Fatalf("unexpected line %v", hfl)
+	}
+
+	// disabled without Until, re-enabled manually
+	if info, ok := DisabledInfo(reparsed.HostsFileLines[2]); !ok || info.By != "bob" || !info.Until.IsZero() {
+		t.Fatalf("unexpected disable info %v %v", info, ok)
+	}
+
+	if err := reparsed.Enable(SelectHostname("cache")); err != nil {
+		t.Fatal(err)
+	}
+
+	if reparsed.HostsFileLines[2].IsCommented {
+		t.Fatal("expected cache to be enabled")
+	}
+}
+
+func TestEnableSkipsPlainComments(t *testing.T) {
+	h, err := InitFromString("# 10.0.0.1 db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Enable(SelectHostname("db")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !h.HostsFileLines[0].IsCommented {
+		t.Fatal("expected lines not disabled by Disable to be left alone")
+	}
+}
+
+func TestDisableReadableMetadata(t *testing.T) {
+	h, err := InitFromString("10.0.0.1 db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reason := "DNS migration, see OPS-12 #ops_team 100%"
+	if err := h.Disable(SelectHostname("db"), DisableOptions{Reason: reason, By: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if raw := h.HostsFileLines[0].Raw; !strings.Contains(raw, "disabled-reason=DNS_migration,_see_OPS-12_%23ops%5Fteam_100%25") {
+		t.Fatalf("unexpected line %q", raw)
+	}
+
+	reparsed, err := InitFromString(h.RenderHostsFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info, ok := DisabledInfo(reparsed.HostsFileLines[0]); !ok || info.Reason != reason {
+		t.Fatalf("unexpected disable info %v %v", info, ok)
+	}
+}
+
+func TestReenableDueConflict(t *testing.T) {
+	until := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	newHostsFile := func() *HostsFile {
+		h, err := InitFromString("10.0.0.1 db\n10.0.0.9 web")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := h.Disable(SelectHostname("db"), DisableOptions{Until: until}); err != nil {
+			t.Fatal(err)
+		}
+
+		// db is mapped elsewhere while disabled
+		if _, _, err := h.AddHostsFileLine("10.0.0.2", "db", ""); err != nil {
+			t.Fatal(err)
+		}
+
+		return h
+	}
+
+	h := newHostsFile()
+	var conflict *HostnameConflictError
+	if _, _, err := h.ReenableDueWithPolicy(until, ErrorOnConflict); !errors.As(err, &conflict) || conflict.Row != 2 {
+		t.Fatalf("expected a conflict at row 2, got %v", err)
+	}
+
+	if rows, _, err := h.ReenableDueWithPolicy(until, KeepExisting); err != nil || len(rows) != 0 || !h.HostsFileLines[0].IsCommented {
+		t.Fatalf("expected db to stay disabled, got %v %v", rows, err)
+	}
+
+	if rows, _, err := h.ReenableDueWithPolicy(until, AllowMultiple); err != nil || len(rows) != 1 || len(h.GetHostsFileLinesByHostname("db")) != 2 {
+		t.Fatalf("expected both mappings, got %v %v", rows, err)
+	}
+
+	h = newHostsFile()
+	rows, err := h.ReenableDue(until)
+	if err != nil || len(rows) != 1 || rows[0] != 0 {
+		t.Fatalf("expected row 0 to be re-enabled, got %v %v", rows, err)
+	}
+
+	lines := h.GetHostsFileLinesByHostname("db")
+	if len(lines) != 1 || lines[0].Address.String() != "10.0.0.1" || lines[0].IsCommented || len(h.HostsFileLines) != 2 {
+		t.Fatalf("expected db to be mapped to 10.0.0.1 only, got %v", h.HostsFileLines)
+	}
+}
+
+func TestReenableDueSameHostname(t *testing.T) {
+	until := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	disabledAt := func(at time.Time) string {
+		return "disabled=" + formatAnnotationTime(at) + " disabled-until=" + formatAnnotationTime(until)
+	}
+
+	h, err := InitFromString(strings.Join([]string{
+		"# 10.0.0.1 db # libhosty: " + disabledAt(until.Add(-2*time.Hour)),
+		"# 10.0.0.2 db # libhosty: " + disabledAt(until.Add(-time.Hour)),
+		"10.0.0.3 db",
+		"10.0.0.4 web",
+	}, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enabled, removed, err := h.ReenableDueWithPolicy(until, ReplaceExisting)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the most recently disabled line wins, the replaced mapping is reported
+	if !slices.Equal(enabled, []int{1}) || !slices.Equal(removed, []int{2}) {
+		t.Fatalf("unexpected rows %v %v", enabled, removed)
+	}
+
+	if !h.HostsFileLines[0].IsCommented || h.HostsFileLines[1].IsCommented || len(h.HostsFileLines) != 3 {
+		t.Fatalf("unexpected lines %v", h.HostsFileLines)
+	}
+
+	for _, hfl := range h.GetHostsFileLinesByHostname("db") {
+		if !hfl.IsCommented && hfl.Address.String() != "10.0.0.2" {
+			t.Fatalf("expected db to map to 10.0.0.2 only, got %v", h.HostsFileLines)
+		}
+	}
+}
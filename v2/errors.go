@@ -28,6 +28,10 @@ var ErrHostnameNotFound = errors.New("cannot find a line with given hostname")
 // example: When using InitFromString(lines), a hostsfilepath is set to "" on the returned HostsFile object
 var ErrPathNotConfigured = errors.New("hostsfile path is not configure. Either configure a hostsfilepath or use WriteHostsFileTo(path)")
 
+// ErrConcurrentModification used when the hosts file changes between the preparation
+// of a mutation and its application, the mutation is not applied
+var ErrConcurrentModification = errors.New("hosts file has been modified concurrently")
+
 // ErrUnknown used when we don't know what's happened
 var ErrUnknown = errors.New("unknown error")
 
@@ -40,3 +44,90 @@ func ErrCannotParseIPAddress(ip string) error {
 func ErrUnrecognizedOS(os string) error {
 	return fmt.Errorf("unrecognized OS: %s", os)
 }
+
+// HostnameConflictError used when adding a hostname that is already mapped to a different address
+// with the ErrorOnConflict policy. Row and Line refer to the conflicting entry
+type HostnameConflictError struct {
+	Hostname string
+	Row      int
+	Line     HostsFileLine
+}
+
+func (e *HostnameConflictError) Error() string {
+	return fmt.Sprintf("hostname %s is already mapped to %s at row %d", e.Hostname, e.Line.Address, e.Row)
+}
+
+// ErrUnknownDialect used when unable to find a dialect by name
+func ErrUnknownDialect(name string) error {
+	return fmt.Errorf("unknown dialect: %s", name)
+}
+
+// ErrUnsupportedAddress used when the given address is not supported by the dialect, such as a zoned IPv6 address
+func ErrUnsupportedAddress(address, dialect string) error {
+	return fmt.Errorf("address %s is not supported by the %s dialect", address, dialect)
+}
+
+// ErrLockTimeout used when the hosts file lock cannot be acquired in time
+var ErrLockTimeout = errors.New("timeout acquiring hosts file lock")
+
+// ErrUnknownLine used in strict mode when a line cannot be parsed
+func ErrUnknownLine(row int, raw string) error {
+	return fmt.Errorf("cannot parse line %d: %s", row+1, raw)
+}
+
+// ErrReadOnlyFS used when writing to a read-only filesystem
+var ErrReadOnlyFS = errors.New("filesystem is read-only")
+
+// ErrTooManySymlinks used when too many symlinks are found resolving a path
+var ErrTooManySymlinks = errors.New("too many levels of symbolic links")
+
+// ErrUnsupportedImage used when an image tarball cannot be handled
+func ErrUnsupportedImage(reason string) error {
+	return fmt.Errorf("unsupported image: %s", reason)
+}
+
+// ErrNoAuditRecord used when no audit record matches the requested time
+var ErrNoAuditRecord = errors.New("no audit record found")
+
+// ErrIntegrityHeaderNotFound used when verifying a hosts file without integrity header
+var ErrIntegrityHeaderNotFound = errors.New("integrity header not found")
+
+// ErrIntegrityMismatch used when a hosts file does not match its integrity header
+var ErrIntegrityMismatch = errors.New("hosts file does not match its integrity header")
+
+// ErrInvalidAnnotation used when an annotation cannot be rendered in a comment
+func ErrInvalidAnnotation(key, value string) error {
+	return fmt.Errorf("invalid annotation: %q=%q", key, value)
+}
+
+// ErrProfileNotFound used when no entry belongs to the given profile
+func ErrProfileNotFound(name string) error {
+	return fmt.Errorf("profile not found: %s", name)
+}
+
+// ErrInvalidProfile used when a profile name cannot be stored in the comma separated profile lists
+func ErrInvalidProfile(name string) error {
+	return fmt.Errorf("invalid profile name: %q", name)
+}
+
+// ErrUnsupportedBaseline used when a baseline has been recorded with an unknown format version
+func ErrUnsupportedBaseline(version int) error {
+	return fmt.Errorf("unsupported baseline version: %d", version)
+}
+
+// ErrBaselineHashMismatch used when the entries of a baseline do not match its recorded hash
+var ErrBaselineHashMismatch = errors.New("baseline entries do not match the baseline hash")
+
+// PolicyViolationError used when a Policy vetoes a mutation
+type PolicyViolationError struct {
+	Rule     PolicyRule
+	Op       Operation
+	Row      int
+	Line     HostsFileLine
+	Hostname string
+	Reason   string
+}
+
+func (e *PolicyViolationError) Error() string {
+	return fmt.Sprintf("policy violation (%s): %s at row %d: %s", e.Rule, e.Op, e.Row, e.Reason)
+}
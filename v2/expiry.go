@@ -0,0 +1,161 @@
+package libhosty
+
+import "time"
+
+// AnnotationExpires is the annotation holding the expiry of an entry,
+// as an RFC 3339 time or a 2006-01-02 date (midnight UTC)
+const AnnotationExpires = "expires"
+
+// expiryDateLayout is the date only layout accepted for AnnotationExpires
+const expiryDateLayout = "2006-01-02"
+
+// PruneAction define a safe type for what PruneExpired does with expired entries
+type PruneAction int
+
+const (
+	//PruneRemove removes expired entries (default)
+	PruneRemove PruneAction = iota
+
+	//PruneComment comments out expired entries
+	PruneComment
+)
+
+func (pa PruneAction) String() string {
+	switch pa {
+	case PruneRemove:
+		return "prune-remove"
+	case PruneComment:
+		return "prune-comment"
+	default:
+		return "prune-action-unknown"
+	}
+}
+
+// PruneOptions holds options for PruneExpiredWithOptions.
+// the zero value behaves like PruneExpired
+type PruneOptions struct {
+	//Action defines what to do with expired entries
+	Action PruneAction
+}
+
+// PrunedEntry holds an expired line handled by PruneExpired
+type PrunedEntry struct {
+	//Row is the row of the line before pruning
+	Row int
+
+	//Line is a copy of the line before pruning
+	Line HostsFileLine
+
+	//Expires is the expiry of the line
+	Expires time.Time
+
+	//Action is what has been done with the line
+	Action PruneAction
+}
+
+// PruneExpired removes every active address line expired at now.
+// it returns the pruned lines.
+// error is not nil if a hook vetoes the change, no line is pruned in that case
+func (h *HostsFile) PruneExpired(now time.Time) ([]PrunedEntry, error) {
+	return h.PruneExpiredWithOptions(now, PruneOptions{})
+}
+
+// PruneExpiredWithOptions removes or comments out, as defined by opts,
+// every active address line expired at now.
+// lines with an invalid expiry are left alone.
+// it returns the pruned lines.
+// error is not nil if a hook vetoes the change, no line is pruned in that case
+func (h *HostsFile) PruneExpiredWithOptions(now time.Time, opts PruneOptions) ([]PrunedEntry, error) {
+	rows := make([]int, 0)
+	old := make([]HostsFileLine, 0)
+	pruned := make([]PrunedEntry, 0)
+
+	h.Lock()
+	for idx, hfl := range h.HostsFileLines {
+		if hfl.Type != LineTypeAddress || hfl.IsCommented {
+			continue
+		}
+
+		expires, ok := ExpiresAt(hfl)
+		if !ok || now.Before(expires) {
+			continue
+		}
+
+		rows = append(rows, idx)
+		old = append(old, hfl)
+		pruned = append(pruned, PrunedEntry{
+			Row:     idx,
+			Line:    cloneHostsFileLines([]HostsFileLine{hfl})[0],
+			Expires: expires,
+			Action:  opts.Action,
+		})
+	}
+	old = cloneHostsFileLines(old)
+	h.Unlock()
+
+	var err error
+	if opts.Action == PruneComment {
+		err = h.setCommented(rows, old, true)
+	} else {
+		err = h.removeLines(rows, old)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pruned, nil
+}
+
+// SetExpiry sets the expiry of the address line at the given row.
+// error is not nil if the row is not an address line or a hook vetoes the change
+func (h *HostsFile) SetExpiry(row int, expires time.Time) error {
+	return h.SetAnnotation(row, AnnotationExpires, formatAnnotationTime(expires))
+}
+
+// ExpiresAt returns the expiry of the given line, ok is false if it has none or it is invalid
+func ExpiresAt(hfl HostsFileLine) (expires time.Time, ok bool) {
+	value, ok := hfl.Annotations[AnnotationExpires]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	if expires, err := time.Parse(time.RFC3339, value); err == nil {
+		return expires, true
+	}
+
+	if expires, err := time.Parse(expiryDateLayout, value); err == nil {
+		return expires, true
+	}
+
+	return time.Time{}, false
+}
+
+// formatAnnotationTime returns the annotation value of the given time, RFC 3339 in UTC
+func formatAnnotationTime(expires time.Time) string {
+	return expires.UTC().Format(time.RFC3339)
+}
+
+// annotations returns the annotations defined by opts, with the expiry and the profile if any
+func (opts AddOptions) annotations() map[string]string {
+	expires := opts.Expires
+	if expires.IsZero() && opts.TTL > 0 {
+		expires = time.Now().Add(opts.TTL)
+	}
+
+	if expires.IsZero() && opts.Profile == "" {
+		return opts.Annotations
+	}
+
+	annotations := mergeAnnotations(opts.Annotations, nil)
+
+	if !expires.IsZero() {
+		annotations[AnnotationExpires] = formatAnnotationTime(expires)
+	}
+
+	if opts.Profile != "" {
+		annotations[AnnotationProfile] = opts.Profile
+	}
+
+	return annotations
+}
@@ -0,0 +1,88 @@
+package libhosty
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPruneExpired(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	h, err := InitFromString("127.0.0.1 localhost\n" +
+		"10.0.0.1 old # libhosty: expires=2026-01-01\n" +
+		"10.0.0.2 soon # libhosty: expires=2026-06-01T12:00:00Z\n" +
+		"10.0.0.3 later # libhosty: expires=2027-01-01\n" +
+		"10.0.0.4 broken # libhosty: expires=tomorrow\n" +
+		"# 10.0.0.5 disabled # libhosty: expires=2026-01-01\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := h.PruneExpired(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pruned) != 2 || pruned[0].Row != 1 || pruned[1].Row != 2 || pruned[0].Action != PruneRemove {
+		t.Fatalf("unexpected pruned entries %v", pruned)
+	}
+
+	if pruned[0].Line.Hostnames[0] != "old" || !pruned[0].Expires.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected pruned entry %v", pruned[0])
+	}
+
+	for _, hn := range []string{"old", "soon"} {
+		if _, _, err := h.LookupByHostname(hn); err != ErrHostnameNotFound {
+			t.Fatalf("expected %s to be removed", hn)
+		}
+	}
+
+	for _, hn := range []string{"later", "broken", "disabled"} {
+		if _, _, err := h.LookupByHostname(hn); err != nil {
+			t.Fatalf("expected %s to be kept", hn)
+		}
+	}
+
+	if pruned, err := h.PruneExpired(now); err != nil || len(pruned) != 0 {
+		t.Fatalf("expected nothing to prune, got %v %v", pruned, err)
+	}
+}
+
+func TestPruneExpiredComment(t *testing.T) {
+	h := New(WithDialect(DialectGlibc))
+
+	now := time.Now()
+
+	if _, _, err := h.AddHostsFileLineWithOptions("10.0.0.1", "tmp", "debugging", AddOptions{TTL: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := h.AddHostsFileLineWithOptions("10.0.0.2", "fixed", "", AddOptions{Expires: now.Add(48 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if expires, ok := ExpiresAt(h.HostsFileLines[0]); !ok || expires.Before(now) || expires.After(now.Add(time.Hour+time.Second)) {
+		t.Fatalf("unexpected expiry %v %v", expires, ok)
+	}
+
+	pruned, err := h.PruneExpiredWithOptions(now.Add(2*time.Hour), PruneOptions{Action: PruneComment})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pruned) != 1 || pruned[0].Line.Hostnames[0] != "tmp" {
+		t.Fatalf("unexpected pruned entries %v", pruned)
+	}
+
+	if !h.HostsFileLines[0].IsCommented || h.HostsFileLines[0].Comment != "debugging" || h.HostsFileLines[1].IsCommented {
+		t.Fatalf("unexpected lines %v", h.HostsFileLines)
+	}
+
+	if err := h.SetExpiry(1, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if pruned, err := h.PruneExpired(now.Add(2 * time.Hour)); err != nil || len(pruned) != 1 || pruned[0].Row != 1 {
+		t.Fatalf("unexpected pruned entries %v %v", pruned, err)
+	}
+}
@@ -22,22 +22,32 @@ func lineFormatter(hfl HostsFileLine) string {
 		return fmt.Sprintf("# %s", hfl.Comment)
 	}
 
-	// address lines
+	// address lines, the comment is followed by the annotations
+	comment := formatComment(hfl)
 
 	// check if it's a commented line
 	if hfl.IsCommented {
 		// check if there's a comment for that line
-		if len(hfl.Comment) > 0 {
-			return fmt.Sprintf("# %-16s %s #%s", hfl.Address, strings.Join(hfl.Hostnames, " "), hfl.Comment)
+		if len(comment) > 0 {
+			return fmt.Sprintf("# %-16s %s #%s", formatAddress(hfl), strings.Join(hfl.Hostnames, " "), comment)
 		}
 
-		return fmt.Sprintf("# %-16s %s", hfl.Address, strings.Join(hfl.Hostnames, " "))
+		return fmt.Sprintf("# %-16s %s", formatAddress(hfl), strings.Join(hfl.Hostnames, " "))
 	}
 
 	// return the actual hosts entry
-	if len(hfl.Comment) > 0 {
-		return fmt.Sprintf("%-16s %s #%s", hfl.Address, strings.Join(hfl.Hostnames, " "), hfl.Comment)
+	if len(comment) > 0 {
+		return fmt.Sprintf("%-16s %s #%s", formatAddress(hfl), strings.Join(hfl.Hostnames, " "), comment)
 	}
 
-	return fmt.Sprintf("%-16s %s", hfl.Address, strings.Join(hfl.Hostnames, " "))
+	return fmt.Sprintf("%-16s %s", formatAddress(hfl), strings.Join(hfl.Hostnames, " "))
+}
+
+// formatAddress returns the address of the given HostsFileLine, with its zone if any
+func formatAddress(hfl HostsFileLine) string {
+	if hfl.Zone != "" {
+		return hfl.Address.String() + "%" + hfl.Zone
+	}
+
+	return hfl.Address.String()
 }
@@ -0,0 +1,209 @@
+package libhosty
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FS is a writable filesystem used to read and write hosts files
+type FS interface {
+	//ReadFile reads the named file and returns its contents
+	ReadFile(name string) ([]byte, error)
+
+	//WriteFile writes data to the named file, creating it with perm if necessary
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// fileLocker is implemented by filesystems supporting advisory locks
+type fileLocker interface {
+	lockFile(ctx context.Context, name string, exclusive bool) (func(), error)
+}
+
+// fileAttrsWriter is implemented by filesystems able to set the owner of created files
+type fileAttrsWriter interface {
+	writeFileAttrs(name string, data []byte, attrs fileAttrs) error
+}
+
+// fileAppender is implemented by filesystems able to append to a file without rewriting it
+type fileAppender interface {
+	appendFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// OSFS is the FS backed by the operating system filesystem
+type OSFS struct{}
+
+// ReadFile reads the named file with os.ReadFile
+func (OSFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+// WriteFile writes the named file, following symlinks.
+// the file is replaced atomically when possible, and written in place
+// when it cannot be replaced, like bind mounts.
+// mode, owner and extended attributes of an existing file are preserved
+func (OSFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return writeFile(name, data, fileAttrs{perm: perm})
+}
+
+// writeFileAttrs writes the named file, using attrs if the file is created
+func (OSFS) writeFileAttrs(name string, data []byte, attrs fileAttrs) error {
+	return writeFile(name, data, attrs)
+}
+
+// appendFile appends data to the named file, creating it with perm if necessary
+func (OSFS) appendFile(name string, data []byte, perm fs.FileMode) error {
+	return appendOSFile(name, data, perm)
+}
+
+// lockFile acquires an advisory lock on the named file
+func (OSFS) lockFile(ctx context.Context, name string, exclusive bool) (func(), error) {
+	return lockFile(ctx, name, exclusive)
+}
+
+// MemFS is an in-memory FS, safe for concurrent use
+type MemFS struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	perms map[string]fs.FileMode
+}
+
+// NewMemFS returns a new, empty, in-memory FS
+func NewMemFS() *MemFS {
+	return &MemFS{
+		files: make(map[string][]byte),
+		perms: make(map[string]fs.FileMode),
+	}
+}
+
+// ReadFile returns a copy of the named file contents
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return append([]byte{}, data...), nil
+}
+
+// WriteFile stores a copy of data as the named file contents,
+// perm is kept only when the file is created
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+
+	if _, ok := m.perms[name]; !ok {
+		m.perms[name] = perm
+	}
+
+	m.files[name] = append([]byte{}, data...)
+
+	return nil
+}
+
+// appendFile appends a copy of data to the named file contents
+func (m *MemFS) appendFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+
+	if _, ok := m.perms[name]; !ok {
+		m.perms[name] = perm
+	}
+
+	m.files[name] = append(append([]byte{}, m.files[name]...), data...)
+
+	return nil
+}
+
+// Perm returns the permissions of the named file
+func (m *MemFS) Perm(name string) (fs.FileMode, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	perm, ok := m.perms[filepath.Clean(name)]
+	return perm, ok
+}
+
+// readOnlyFS adapts an fs.FS to the FS interface
+type readOnlyFS struct {
+	fsys fs.FS
+}
+
+// ReadOnlyFS returns an FS reading from the given fs.FS, writes always fail.
+// absolute names are resolved from the fs.FS root, so /etc/hosts reads etc/hosts
+func ReadOnlyFS(fsys fs.FS) FS {
+	return readOnlyFS{fsys: fsys}
+}
+
+// ReadFile reads the named file from the fs.FS
+func (r readOnlyFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(r.fsys, ioFSName(name))
+}
+
+// WriteFile always returns ErrReadOnlyFS
+func (r readOnlyFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return &fs.PathError{Op: "write", Path: name, Err: ErrReadOnlyFS}
+}
+
+// ioFSName converts name to an unrooted, slash-separated, fs.FS name
+func ioFSName(name string) string {
+	name = path.Clean(filepath.ToSlash(name))
+	name = strings.TrimLeft(name, "/")
+
+	if name == "" {
+		return "."
+	}
+
+	return name
+}
+
+// appendFile appends data to the named file of fsys, creating it with perm if necessary.
+// filesystems without append support read and rewrite the whole file
+func appendFile(fsys FS, name string, data []byte, perm fs.FileMode) error {
+	if a, ok := fsys.(fileAppender); ok {
+		return a.appendFile(name, data, perm)
+	}
+
+	current, err := fsys.ReadFile(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return fsys.WriteFile(name, append(current, data...), perm)
+}
+
+// appendOSFile appends data to the named file, creating it with perm if necessary
+func appendOSFile(name string, data []byte, perm fs.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// filesystem returns the FS configured on the HostsFile, the OS one if none is configured
+func (h *HostsFile) filesystem() FS {
+	if h.fs == nil {
+		return OSFS{}
+	}
+
+	return h.fs
+}
@@ -0,0 +1,64 @@
+package libhosty
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMemFS(t *testing.T) {
+	mfs := NewMemFS()
+
+	if _, err := mfs.ReadFile("/etc/hosts"); err == nil {
+		t.Fatal("should fail with missing file")
+	}
+
+	if err := mfs.WriteFile("/etc/hosts", []byte("127.0.0.1 localhost\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := Open(context.Background(), "/etc/hosts", WithFS(mfs))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.AddHostsFileLine("10.0.0.1", "mem.local", "")
+
+	if err := h.WriteHostsFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	hfl, err := ParseHostsFileFS(mfs, "/etc/hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hfl) != 3 || hfl[2].Hostnames[0] != "mem.local" {
+		t.Fatalf("unexpected lines: %v", hfl)
+	}
+
+	// permissions are kept from file creation
+	if perm, _ := mfs.Perm("/etc/hosts"); perm != 0600 {
+		t.Fatalf("wants %o got %o", 0600, perm)
+	}
+}
+
+func TestReadOnlyFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/hosts": &fstest.MapFile{Data: []byte("127.0.0.1 localhost\n")},
+	}
+
+	h, err := Open(context.Background(), "/etc/hosts", WithIOFS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(h.GetHostsFileLinesByHostname("localhost")) != 1 {
+		t.Fatal("localhost should be loaded")
+	}
+
+	if err := h.WriteHostsFile(); !errors.Is(err, ErrReadOnlyFS) {
+		t.Fatalf("wants %v got %v", ErrReadOnlyFS, err)
+	}
+}
@@ -1,87 +1,58 @@
 package libhosty
 
-import "runtime"
-
-// RestoreTemplate tries to restore the default hostsfile based on runtime.GOOS result
-// returns true if restore goes well
+// RestoreTemplate restores the default hostsfile of the configured dialect
+// returns true if restore goes well, false if it fails or a hook vetoes it
 func (h *HostsFile) RestoreTemplate() bool {
-	var hfl []HostsFileLine
-	// var err error
+	hfl, err := parser([]byte(h.dialect().Template), h.parserConfig())
 
-	switch runtime.GOOS {
-	case "windows":
-		hfl, _ = ParseHostsFileFromString(windowsHostsTemplate)
-	case "docker":
-		hfl, _ = ParseHostsFileFromString(dockerDesktopTemplate)
-	case "linux|unix":
-		hfl, _ = ParseHostsFileFromString(linuxHostsTemplate)
-	case "darwin":
-		hfl, _ = ParseHostsFileFromString(darwinHostsTemplate)
-	default:
-		// hfl = nil
-		hfl, _ = ParseHostsFileFromString(linuxHostsTemplate)
-	}
-
-	if hfl != nil {
-		h.HostsFileLines = hfl
-		return true
+	if err == nil {
+		return h.replaceLines(h.snapshot(), hfl) == nil
 	}
 
 	return false
 }
 
 // RestoreNamedTemplate restored the named template as the current hostsfile
-// returns true if restore goes well
+// returns true if restore goes well, false if it fails or a hook vetoes it
 func (h *HostsFile) RestoreNamedTemplate(template string) bool {
-	var hfl []HostsFileLine
-	// var err error
-
-	switch template {
-	case "windows":
-		hfl, _ = ParseHostsFileFromString(windowsHostsTemplate)
-	case "docker":
-		hfl, _ = ParseHostsFileFromString(dockerDesktopTemplate)
-	case "linux|unix":
-		hfl, _ = ParseHostsFileFromString(linuxHostsTemplate)
-	case "darwin":
-		hfl, _ = ParseHostsFileFromString(darwinHostsTemplate)
-	default:
-		// hfl = nil
-		hfl, _ = ParseHostsFileFromString(linuxHostsTemplate)
-	}
+	hfl, err := parser([]byte(namedTemplate(template)), h.parserConfig())
 
-	if hfl != nil {
-		h.HostsFileLines = hfl
-		return true
+	if err == nil {
+		return h.replaceLines(h.snapshot(), hfl) == nil
 	}
 
 	return false
 }
 
 // AppendNamedTemplate appends the named template to the current hostsfile
-// returns true if restore goes well
+// returns true if restore goes well, false if it fails or a hook vetoes it
 func (h *HostsFile) AppendNamedTemplate(template string) bool {
-	var hfl []HostsFileLine
-	// var err error
+	hfl, err := parser([]byte(namedTemplate(template)), h.parserConfig())
+
+	if err == nil {
+		return h.insertLines(len(h.HostsFileLines), hfl...) == nil
+	}
 
+	return false
+}
+
+// namedTemplate returns the template with the given name,
+// dialect names are accepted too. defaults to the linux template
+func namedTemplate(template string) string {
 	switch template {
 	case "windows":
-		hfl, _ = ParseHostsFileFromString(windowsHostsTemplate)
+		return windowsHostsTemplate
 	case "docker":
-		hfl, _ = ParseHostsFileFromString(dockerDesktopTemplate)
-	case "linux|unix":
-		hfl, _ = ParseHostsFileFromString(linuxHostsTemplate)
+		return dockerDesktopTemplate
+	case "linux", "unix", "linux|unix":
+		return linuxHostsTemplate
 	case "darwin":
-		hfl, _ = ParseHostsFileFromString(darwinHostsTemplate)
-	default:
-		// hfl = nil
-		hfl, _ = ParseHostsFileFromString(linuxHostsTemplate)
+		return darwinHostsTemplate
 	}
 
-	if hfl != nil {
-		h.HostsFileLines = append(h.HostsFileLines, hfl...)
-		return true
+	if d, err := DialectByName(template); err == nil {
+		return d.Template
 	}
 
-	return false
+	return linuxHostsTemplate
 }
@@ -0,0 +1,337 @@
+package libhosty
+
+import "golang.org/x/exp/slices"
+
+// Operation define a safe type for the kind of mutation applied to a hosts file
+type Operation int
+
+const (
+	//OpAdd new lines are inserted
+	OpAdd Operation = iota
+
+	//OpRemove lines are removed
+	OpRemove
+
+	//OpModify lines are edited, like hostnames or comments changes
+	OpModify
+
+	//OpComment address lines are commented out
+	OpComment
+
+	//OpUncomment address lines are uncommented
+	OpUncomment
+
+	//OpReplace the whole content is replaced, like templates, Repack and Reload
+	OpReplace
+)
+
+func (op Operation) String() string {
+	switch op {
+	case OpAdd:
+		return "operation-add"
+	case OpRemove:
+		return "operation-remove"
+	case OpModify:
+		return "operation-modify"
+	case OpComment:
+		return "operation-comment"
+	case OpUncomment:
+		return "operation-uncomment"
+	case OpReplace:
+		return "operation-replace"
+	default:
+		return "operation-unknown"
+	}
+}
+
+// Mutation describes a change about to be applied, or just applied, to a hosts file.
+// lines are copies, changing them has no effect on the hosts file
+type Mutation struct {
+	//Op is the kind of mutation
+	Op Operation
+
+	//Rows are the affected rows, as indexes before the mutation.
+	//for OpAdd they are the rows of the new lines after the mutation,
+	//for OpReplace they are every row of the previous content
+	Rows []int
+
+	//Old are the affected lines before the mutation, nil for OpAdd
+	Old []HostsFileLine
+
+	//New are the affected lines after the mutation, nil for OpRemove.
+	//for OpReplace they are the whole new content
+	New []HostsFileLine
+}
+
+// Hook is called around every mutation of a hosts file.
+// Before is called before the mutation is applied and can veto it by returning an error,
+// which is returned by the mutating method. After is called once the mutation is applied.
+// hooks are called without holding the hosts file lock, if the content changes
+// in the meantime the mutation is dropped and ErrConcurrentModification is returned.
+// the legacy methods without an error result, such as RemoveHostsFileLinesByRegexp,
+// leave out the rows vetoed by a Policy and apply the rest, other vetoes are ignored:
+// use their BySelector variants to get the error
+type Hook interface {
+	//Before is called before m is applied, an error vetoes it
+	Before(h *HostsFile, m Mutation) error
+
+	//After is called after m has been applied
+	After(h *HostsFile, m Mutation)
+}
+
+// writeHook is implemented by hooks that need to know when the hosts file is written
+type writeHook interface {
+	afterWrite(h *HostsFile, path string, data []byte) error
+}
+
+// HookFuncs is a Hook built from functions, nil functions are skipped
+type HookFuncs struct {
+	//BeforeFunc is called by Before
+	BeforeFunc func(h *HostsFile, m Mutation) error
+
+	//AfterFunc is called by After
+	AfterFunc func(h *HostsFile, m Mutation)
+}
+
+// Before calls BeforeFunc, if any
+func (hf HookFuncs) Before(h *HostsFile, m Mutation) error {
+	if hf.BeforeFunc == nil {
+		return nil
+	}
+
+	return hf.BeforeFunc(h, m)
+}
+
+// After calls AfterFunc, if any
+func (hf HookFuncs) After(h *HostsFile, m Mutation) {
+	if hf.AfterFunc != nil {
+		hf.AfterFunc(h, m)
+	}
+}
+
+// WithHook adds a hook called around every mutation
+func WithHook(hook Hook) Option {
+	return func(h *HostsFile) {
+		h.hooks = append(h.hooks, hook)
+	}
+}
+
+// AddHook adds a hook called around every mutation
+func (h *HostsFile) AddHook(hook Hook) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.hooks = append(h.hooks, hook)
+}
+
+// mutate calls the before hooks, applies m with apply while holding the lock,
+// then calls the after hooks. the caller must not hold the lock.
+// m.Old is checked against the current content before applying,
+// error is ErrConcurrentModification if it changed meanwhile
+func (h *HostsFile) mutate(m Mutation, apply func()) error {
+	h.Lock()
+	hooks := append([]Hook{}, h.hooks...)
+	h.Unlock()
+
+	// hooks get copies, keep ours to check the content did not change
+	old := cloneHostsFileLines(m.Old)
+
+	for _, hook := range hooks {
+		if err := hook.Before(h, m); err != nil {
+			return err
+		}
+	}
+
+	h.Lock()
+	if !h.holds(m.Op, m.Rows, old) {
+		h.Unlock()
+		return ErrConcurrentModification
+	}
+	apply()
+	h.Unlock()
+
+	for _, hook := range hooks {
+		hook.After(h, m)
+	}
+
+	return nil
+}
+
+// holds reports whether the current content still holds old at the given rows.
+// for OpAdd the insertion row must still exist, for OpReplace old is the whole content.
+// the caller must hold the lock
+func (h *HostsFile) holds(op Operation, rows []int, old []HostsFileLine) bool {
+	switch op {
+	case OpAdd:
+		return rows[0] <= len(h.HostsFileLines)
+	case OpReplace:
+		return equalHostsFileLinesSlice(old, h.HostsFileLines)
+	}
+
+	for i, row := range rows {
+		if row >= len(h.HostsFileLines) || !equalHostsFileLines(old[i], h.HostsFileLines[row]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// afterWrite notifies the hooks that data has been written to path
+func (h *HostsFile) afterWrite(path string, data []byte) error {
+	h.Lock()
+	hooks := append([]Hook{}, h.hooks...)
+	h.Unlock()
+
+	for _, hook := range hooks {
+		if wh, ok := hook.(writeHook); ok {
+			if err := wh.afterWrite(h, path, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// insertLines inserts lines at the given row
+func (h *HostsFile) insertLines(row int, lines ...HostsFileLine) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	rows := make([]int, len(lines))
+	for i := range rows {
+		rows[i] = row + i
+	}
+
+	m := Mutation{
+		Op:   OpAdd,
+		Rows: rows,
+		New:  cloneHostsFileLines(lines),
+	}
+
+	return h.mutate(m, func() {
+		h.HostsFileLines = slices.Insert(h.HostsFileLines, row, lines...)
+	})
+}
+
+// removeRows removes the given rows
+func (h *HostsFile) removeRows(rows []int) error {
+	rows = sortedRows(rows)
+
+	return h.removeLines(rows, h.linesAt(rows))
+}
+
+// removeLines removes the given sorted rows, old are the lines expected at those rows
+func (h *HostsFile) removeLines(rows []int, old []HostsFileLine) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	m := Mutation{
+		Op:   OpRemove,
+		Rows: rows,
+		Old:  cloneHostsFileLines(old),
+	}
+
+	return h.mutate(m, func() {
+		for i := len(rows) - 1; i >= 0; i-- {
+			h.HostsFileLines = slices.Delete(h.HostsFileLines, rows[i], rows[i]+1)
+		}
+	})
+}
+
+// updateRows replaces the given rows with lines, refreshing their Raw field
+func (h *HostsFile) updateRows(op Operation, rows []int, lines []HostsFileLine) error {
+	return h.updateLines(op, rows, h.linesAt(rows), lines)
+}
+
+// updateLines replaces the given rows with lines, refreshing their Raw field.
+// old are the lines expected at those rows
+func (h *HostsFile) updateLines(op Operation, rows []int, old, lines []HostsFileLine) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	lines = cloneHostsFileLines(lines)
+	for i := range lines {
+		lines[i].Raw = lineFormatter(lines[i])
+	}
+
+	m := Mutation{
+		Op:   op,
+		Rows: rows,
+		Old:  cloneHostsFileLines(old),
+		New:  cloneHostsFileLines(lines),
+	}
+
+	return h.mutate(m, func() {
+		for i, row := range rows {
+			h.HostsFileLines[row] = lines[i]
+		}
+	})
+}
+
+// replaceLines replaces the whole content with lines, old is the content lines derive from
+func (h *HostsFile) replaceLines(old, lines []HostsFileLine) error {
+	rows := make([]int, len(old))
+	for i := range rows {
+		rows[i] = i
+	}
+
+	m := Mutation{
+		Op:   OpReplace,
+		Rows: rows,
+		Old:  cloneHostsFileLines(old),
+		New:  cloneHostsFileLines(lines),
+	}
+
+	return h.mutate(m, func() {
+		h.HostsFileLines = lines
+	})
+}
+
+// setCommented comments or uncomments the given rows, old are the lines expected at those rows
+func (h *HostsFile) setCommented(rows []int, old []HostsFileLine, commented bool) error {
+	lines := cloneHostsFileLines(old)
+	for i := range lines {
+		lines[i].IsCommented = commented
+	}
+
+	op := OpUncomment
+	if commented {
+		op = OpComment
+	}
+
+	return h.updateLines(op, rows, old, lines)
+}
+
+// snapshot returns a copy of the current content
+func (h *HostsFile) snapshot() []HostsFileLine {
+	h.Lock()
+	defer h.Unlock()
+
+	return cloneHostsFileLines(h.HostsFileLines)
+}
+
+// linesAt returns copies of the lines at the given rows
+func (h *HostsFile) linesAt(rows []int) []HostsFileLine {
+	h.Lock()
+	defer h.Unlock()
+
+	lines := make([]HostsFileLine, len(rows))
+	for i, row := range rows {
+		lines[i] = h.HostsFileLines[row]
+	}
+
+	return cloneHostsFileLines(lines)
+}
+
+// sortedRows returns a sorted copy of rows, without duplicates
+func sortedRows(rows []int) []int {
+	rows = append([]int{}, rows...)
+	slices.Sort(rows)
+
+	return slices.Compact(rows)
+}
@@ -0,0 +1,148 @@
+package libhosty
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	var before, after []Mutation
+
+	h := New(WithDialect(DialectGlibc), WithHook(HookFuncs{
+		BeforeFunc: func(h *HostsFile, m Mutation) error {
+			before = append(before, m)
+			return nil
+		},
+		AfterFunc: func(h *HostsFile, m Mutation) {
+			after = append(after, m)
+		},
+	}))
+
+	if _, _, err := h.AddHostsFileLine("10.0.0.1", "db", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := h.AddHostsFileLine("10.0.0.1", "cache", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.CommentHostsFileLinesBySelector(SelectHostname("db")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.RemoveHostsFileLinesBySelector(SelectAddress("10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Operation{OpAdd, OpModify, OpComment, OpRemove}
+
+	if len(before) != len(expected) || len(after) != len(expected) {
+		t.Fatalf("expected %d mutations, got %v %v", len(expected), before, after)
+	}
+
+	for i, op := range expected {
+		if before[i].Op != op || after[i].Op != op {
+			t.Fatalf("mutation %d: expected %s, got %s %s", i, op, before[i].Op, after[i].Op)
+		}
+	}
+
+	if m := before[1]; len(m.Old) != 1 || len(m.New) != 1 || len(m.Old[0].Hostnames) != 1 || len(m.New[0].Hostnames) != 2 {
+		t.Fatalf("unexpected modify mutation %v", m)
+	}
+
+	if m := before[2]; m.Old[0].IsCommented || !m.New[0].IsCommented {
+		t.Fatalf("unexpected comment mutation %v", m)
+	}
+
+	if m := before[3]; len(m.Rows) != 1 || m.Rows[0] != 0 || m.New != nil {
+		t.Fatalf("unexpected remove mutation %v", m)
+	}
+}
+
+func TestHooksVeto(t *testing.T) {
+	h, err := InitFromString("127.0.0.1 localhost\n10.0.0.1 db.local\n10.0.0.2 cache.local\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errVeto := errors.New("veto")
+
+	h.AddHook(HookFuncs{
+		BeforeFunc: func(h *HostsFile, m Mutation) error {
+			for _, hfl := range m.Old {
+				if len(hfl.Hostnames) > 0 && hfl.Hostnames[0] == "cache.local" {
+					return errVeto
+				}
+			}
+			return nil
+		},
+		AfterFunc: func(h *HostsFile, m Mutation) {
+			t.Fatalf("after hook called for vetoed mutation %v", m)
+		},
+	})
+
+	// the whole bulk removal is vetoed
+	if err := h.RemoveHostsFileLinesBySelector(SelectRegexp(`\.local$`)); !errors.Is(err, errVeto) {
+		t.Fatalf("expected veto, got %v", err)
+	}
+
+	// the legacy methods ignore the veto
+	h.RemoveHostsFileLinesByRegexp(`\.local$`)
+	h.RemoveHostsFileLineByRow(2)
+
+	if len(h.GetHostsFileLinesByRegexp(`\.local$`)) != 2 {
+		t.Fatal("expected no line to be removed")
+	}
+
+	if err := h.RemoveHostsFileLinesBySelector(SelectRow(2)); !errors.Is(err, errVeto) {
+		t.Fatalf("expected veto, got %v", err)
+	}
+
+	if _, _, err := h.AddHostsFileLine("10.0.0.3", "cache.local", ""); !errors.Is(err, errVeto) {
+		t.Fatalf("expected veto, got %v", err)
+	}
+
+	if err := h.Repack(); err != nil {
+		t.Fatalf("expected no mutation, got %v", err)
+	}
+}
+
+func TestHooksConcurrentModification(t *testing.T) {
+	h, err := InitFromString("10.0.0.1 db.local\n10.0.0.2 cache.local\n# profile\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a concurrent edit lands while the hooks run
+	edit := func() {
+		h.Lock()
+		h.HostsFileLines = h.HostsFileLines[1:]
+		h.Unlock()
+	}
+
+	h.AddHook(HookFuncs{
+		BeforeFunc: func(*HostsFile, Mutation) error {
+			if edit != nil {
+				edit()
+				edit = nil
+			}
+			return nil
+		},
+	})
+
+	if err := h.RemoveHostsFileLinesBySelector(SelectHostname("db.local")); !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("expected ErrConcurrentModification, got %v", err)
+	}
+
+	if len(h.GetHostsFileLinesByHostname("cache.local")) != 1 {
+		t.Fatal("expected the stale removal to be dropped")
+	}
+
+	if err := h.RemoveHostsFileLinesBySelector(SelectHostname("cache.local")); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(h.GetHostsFileLinesByHostname("cache.local")) != 0 {
+		t.Fatal("expected cache.local to be removed")
+	}
+}
@@ -0,0 +1,253 @@
+package libhosty
+
+import (
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// RemoveHostname removes the given hostname from every address line,
+// other hostnames on the same line are preserved.
+// lines left without hostnames are removed.
+// the change is applied as a single mutation, an OpReplace if lines are both edited and removed,
+// so a veto leaves every line in place.
+// error is ErrHostnameNotFound if no line contains the given hostname
+func (h *HostsFile) RemoveHostname(hostname string) error {
+	current := h.snapshot()
+
+	removedRows, removedOld := make([]int, 0), make([]HostsFileLine, 0)
+	editedRows, editedOld, edited := make([]int, 0), make([]HostsFileLine, 0), make([]HostsFileLine, 0)
+
+	for idx, hfl := range current {
+		if hfl.Type != LineTypeAddress {
+			continue
+		}
+
+		hostnames, removed := withoutHostname(hfl.Hostnames, hostname)
+		if !removed {
+			continue
+		}
+
+		// no hostnames left, remove the whole line
+		if len(hostnames) == 0 {
+			removedRows = append(removedRows, idx)
+			removedOld = append(removedOld, hfl)
+			continue
+		}
+
+		editedRows = append(editedRows, idx)
+		editedOld = append(editedOld, hfl)
+
+		hfl.Hostnames = hostnames
+		edited = append(edited, hfl)
+	}
+
+	switch {
+	case len(removedRows) == 0 && len(editedRows) == 0:
+		return ErrHostnameNotFound
+	case len(editedRows) == 0:
+		return h.removeLines(removedRows, removedOld)
+	case len(removedRows) == 0:
+		return h.updateLines(OpModify, editedRows, editedOld, edited)
+	}
+
+	lines := cloneHostsFileLines(current)
+	for idx := len(lines) - 1; idx >= 0; idx-- {
+		if lines[idx].Type == LineTypeAddress {
+			lines, _ = stripHostname(lines, idx, hostname)
+		}
+	}
+
+	return h.replaceLines(current, lines)
+}
+
+// RenameHostname replaces oldHostname with newHostname on every address line,
+// keeping its position among the other hostnames of the line.
+// error is ErrHostnameNotFound if no line contains oldHostname
+func (h *HostsFile) RenameHostname(oldHostname, newHostname string) error {
+	oldHostname = normalizeHostname(oldHostname)
+	newHostname = normalizeHostname(newHostname)
+
+	rows := make([]int, 0)
+	lines := make([]HostsFileLine, 0)
+
+	for idx, hfl := range h.HostsFileLines {
+		if hfl.Type != LineTypeAddress {
+			continue
+		}
+
+		hostnames := make([]string, 0, len(hfl.Hostnames))
+		renamed := false
+
+		for _, hn := range hfl.Hostnames {
+			if normalizeHostname(hn) == oldHostname {
+				hn = newHostname
+				renamed = true
+			}
+
+			// avoid duplicates if newHostname is already on the line
+			if !slices.Contains(hostnames, hn) {
+				hostnames = append(hostnames, hn)
+			}
+		}
+
+		if renamed {
+			hfl.Hostnames = hostnames
+
+			rows = append(rows, idx)
+			lines = append(lines, hfl)
+		}
+	}
+
+	if len(rows) == 0 {
+		return ErrHostnameNotFound
+	}
+
+	return h.updateRows(OpModify, rows, lines)
+}
+
+// MoveHostname maps the given hostname to a new address.
+// the hostname is removed from every uncommented line and added to the new address,
+// other hostnames on the previous lines are preserved.
+// the move is applied as a single OpReplace mutation, a veto leaves the previous mapping in place.
+// it returns the index of the edited (created) line and a pointer to the hostsfileline object.
+// error is not nil if something goes wrong
+func (h *HostsFile) MoveHostname(hostname, ipRaw string) (int, *HostsFileLine, error) {
+	ip, zone, err := h.parseAddress(ipRaw)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	hostname = strings.ToLower(hostname)
+
+	current := h.snapshot()
+	lines := cloneHostsFileLines(current)
+	found := false
+
+	for idx := len(lines) - 1; idx >= 0; idx-- {
+		if lines[idx].Type != LineTypeAddress || lines[idx].IsCommented {
+			continue
+		}
+
+		var removed bool
+		lines, removed = stripHostname(lines, idx, hostname)
+
+		found = found || removed
+	}
+
+	if !found {
+		return -1, nil, ErrHostnameNotFound
+	}
+
+	row, lines := h.placeHostname(lines, ip, zone, hostname, "", AddOptions{})
+	if err := h.replaceLines(current, lines); err != nil {
+		return -1, nil, err
+	}
+
+	return row, &h.HostsFileLines[row], nil
+}
+
+// DisableHostname comments out the given hostname on every uncommented line.
+// if the line holds other hostnames, the given one is moved to a new commented line
+// placed right after the original one, and the rest of the line is preserved.
+// the change is applied as a single mutation, an OpReplace if lines are split,
+// so a veto leaves every line in place.
+// error is ErrHostnameNotFound if no uncommented line contains the given hostname
+func (h *HostsFile) DisableHostname(hostname string) error {
+	hostname = normalizeHostname(hostname)
+
+	current := h.snapshot()
+	lines := cloneHostsFileLines(current)
+
+	// rows commented in place, used if no line is split
+	rows, old := make([]int, 0), make([]HostsFileLine, 0)
+	split := false
+
+	for idx := len(lines) - 1; idx >= 0; idx-- {
+		hfl := lines[idx]
+
+		if hfl.Type != LineTypeAddress || hfl.IsCommented {
+			continue
+		}
+
+		hostnames, removed := withoutHostname(hfl.Hostnames, hostname)
+		if !removed {
+			continue
+		}
+
+		// hostname is the only one, just comment the line
+		if len(hostnames) == 0 {
+			rows = append([]int{idx}, rows...)
+			old = append([]HostsFileLine{current[idx]}, old...)
+
+			lines[idx].IsCommented = true
+			lines[idx].Raw = lineFormatter(lines[idx])
+			continue
+		}
+
+		disabled := HostsFileLine{
+			Type:        LineTypeAddress,
+			Address:     hfl.Address,
+			Zone:        hfl.Zone,
+			Hostnames:   []string{hostname},
+			Comment:     hfl.Comment,
+			Annotations: cloneAnnotations(hfl.Annotations),
+			IsCommented: true,
+		}
+		disabled.Raw = lineFormatter(disabled)
+
+		lines[idx].Hostnames = hostnames
+		lines[idx].Raw = lineFormatter(lines[idx])
+
+		lines = slices.Insert(lines, idx+1, disabled)
+		split = true
+	}
+
+	if len(rows) == 0 && !split {
+		return ErrHostnameNotFound
+	}
+
+	if !split {
+		return h.setCommented(rows, old, true)
+	}
+
+	return h.replaceLines(current, lines)
+}
+
+// stripHostname removes hostname from the given row of lines, removing the whole row if it's left empty.
+// it returns the resulting lines and reports whether the hostname was found
+func stripHostname(lines []HostsFileLine, row int, hostname string) ([]HostsFileLine, bool) {
+	hostnames, removed := withoutHostname(lines[row].Hostnames, hostname)
+	if !removed {
+		return lines, false
+	}
+
+	if len(hostnames) == 0 {
+		return slices.Delete(lines, row, row+1), true
+	}
+
+	lines[row].Hostnames = hostnames
+	lines[row].Raw = lineFormatter(lines[row])
+
+	return lines, true
+}
+
+// withoutHostname returns a copy of hostnames without the given hostname,
+// and reports whether the hostname was found
+func withoutHostname(hostnames []string, hostname string) ([]string, bool) {
+	hostname = normalizeHostname(hostname)
+
+	res := make([]string, 0, len(hostnames))
+	removed := false
+
+	for _, hn := range hostnames {
+		if normalizeHostname(hn) == hostname {
+			removed = true
+			continue
+		}
+
+		res = append(res, hn)
+	}
+
+	return res, removed
+}
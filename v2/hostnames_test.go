@@ -0,0 +1,182 @@
+package libhosty
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+// hostnamesTestEntries are the entries used by the hostname tests,
+// a.local, b.local and c.local share the first line
+var hostnamesTestEntries = [][3]string{
+	{"10.0.0.1", "a.local", "shared"},
+	{"10.0.0.1", "b.local", "shared"},
+	{"10.0.0.1", "c.local", "shared"},
+	{"10.0.0.2", "d.local", ""},
+}
+
+func TestRemoveHostname(t *testing.T) {
+	h := newTestHostsFile(t, hostnamesTestEntries...)
+
+	if err := h.RemoveHostname("b.local"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(h.HostsFileLines[0].Hostnames, []string{"a.local", "c.local"}) {
+		t.Fatalf("wants %q got %q", []string{"a.local", "c.local"}, h.HostsFileLines[0].Hostnames)
+	}
+
+	// removing the last hostname drops the line
+	if err := h.RemoveHostname("d.local"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(h.HostsFileLines) != 1 {
+		t.Fatalf("wants %d lines got %d", 1, len(h.HostsFileLines))
+	}
+
+	if err := h.RemoveHostname("missing.local"); err != ErrHostnameNotFound {
+		t.Fatalf("wants %v got %v", ErrHostnameNotFound, err)
+	}
+}
+
+func TestRenameHostname(t *testing.T) {
+	h := newTestHostsFile(t, hostnamesTestEntries...)
+
+	if err := h.RenameHostname("b.local", "B2.local"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(h.HostsFileLines[0].Hostnames, []string{"a.local", "b2.local", "c.local"}) {
+		t.Fatalf("wants %q got %q", []string{"a.local", "b2.local", "c.local"}, h.HostsFileLines[0].Hostnames)
+	}
+
+	// renaming to an existing hostname does not duplicate it
+	if err := h.RenameHostname("a.local", "c.local"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(h.HostsFileLines[0].Hostnames, []string{"c.local", "b2.local"}) {
+		t.Fatalf("wants %q got %q", []string{"c.local", "b2.local"}, h.HostsFileLines[0].Hostnames)
+	}
+}
+
+func TestMoveHostname(t *testing.T) {
+	h := newTestHostsFile(t, hostnamesTestEntries...)
+
+	idx, hfl, err := h.MoveHostname("b.local", "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !net.IP.Equal(hfl.Address, net.ParseIP("10.0.0.2")) {
+		t.Fatalf("wants %s got %s", "10.0.0.2", hfl.Address)
+	}
+
+	if !slices.Equal(h.HostsFileLines[idx].Hostnames, []string{"d.local", "b.local"}) {
+		t.Fatalf("wants %q got %q", []string{"d.local", "b.local"}, h.HostsFileLines[idx].Hostnames)
+	}
+
+	if !slices.Equal(h.HostsFileLines[0].Hostnames, []string{"a.local", "c.local"}) {
+		t.Fatalf("wants %q got %q", []string{"a.local", "c.local"}, h.HostsFileLines[0].Hostnames)
+	}
+
+	if _, _, err := h.MoveHostname("b.local", "fa.ke.i.p"); err == nil {
+		t.Fatal("should fail with invalid address")
+	}
+}
+
+func TestMoveHostnameVeto(t *testing.T) {
+	h := New(WithPolicy(&Policy{
+		Deny: []DenyRule{{Domain: "bank.com", Allow: []string{"10.0.0.1"}}},
+	}))
+
+	if _, _, err := h.AddHostsFileLine("10.0.0.1", "bank.com", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := h.MoveHostname("bank.com", "6.6.6.6"); err == nil {
+		t.Fatal("expected the move to be vetoed")
+	}
+
+	// the previous mapping is kept
+	if _, ip, err := h.LookupByHostname("bank.com"); err != nil || ip.String() != "10.0.0.1" {
+		t.Fatalf("bank.com should still map to 10.0.0.1, got %v %v", ip, err)
+	}
+}
+
+func TestDisableHostname(t *testing.T) {
+	h := newTestHostsFile(t, hostnamesTestEntries...)
+
+	if err := h.DisableHostname("b.local"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(h.HostsFileLines) != 3 {
+		t.Fatalf("wants %d lines got %d", 3, len(h.HostsFileLines))
+	}
+
+	if h.HostsFileLines[0].IsCommented {
+		t.Fatal("original line should stay uncommented")
+	}
+
+	disabled := h.HostsFileLines[1]
+	if !disabled.IsCommented || !slices.Equal(disabled.Hostnames, []string{"b.local"}) || disabled.Comment != "shared" {
+		t.Fatalf("unexpected disabled line: %v", disabled)
+	}
+
+	// single hostname lines are commented in place
+	if err := h.DisableHostname("d.local"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(h.HostsFileLines) != 3 || !h.HostsFileLines[2].IsCommented {
+		t.Fatalf("d.local line should be commented in place: %v", h.HostsFileLines)
+	}
+
+	if err := h.DisableHostname("d.local"); err != ErrHostnameNotFound {
+		t.Fatalf("wants %v got %v", ErrHostnameNotFound, err)
+	}
+}
+
+func TestHostnameChangesVeto(t *testing.T) {
+	errVeto := errors.New("veto")
+
+	// each change is a single mutation, vetoed as a whole
+	for name, change := range map[string]func(h *HostsFile) error{
+		"RemoveHostname":  func(h *HostsFile) error { return h.RemoveHostname("b") },
+		"DisableHostname": func(h *HostsFile) error { return h.DisableHostname("b") },
+	} {
+		h, err := InitFromString("10.0.0.1 a b\n10.0.0.2 b\n10.0.0.3 c b\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		before := h.RenderHostsFile()
+
+		calls, veto := 0, true
+		h.AddHook(HookFuncs{BeforeFunc: func(*HostsFile, Mutation) error {
+			calls++
+			if veto {
+				return errVeto
+			}
+			return nil
+		}})
+
+		if err := change(h); !errors.Is(err, errVeto) {
+			t.Fatalf("%s: expected veto, got %v", name, err)
+		}
+
+		if after := h.RenderHostsFile(); after != before {
+			t.Fatalf("%s: expected no change, got %q", name, after)
+		}
+
+		calls, veto = 0, false
+
+		if err := change(h); err != nil || calls != 1 {
+			t.Fatalf("%s: expected a single mutation, got %v after %d calls", name, err, calls)
+		}
+	}
+}
@@ -0,0 +1,828 @@
+package libhosty
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// OCI image layout entries
+	imageIndexName  = "index.json"
+	imageLayoutName = "oci-layout"
+
+	// docker save entries
+	imageManifestName = "manifest.json"
+
+	// hosts file location inside layers
+	imageEtcName   = "etc"
+	imageHostsName = "etc/hosts"
+
+	// media types
+	ociImageIndexMediaType      = "application/vnd.oci.image.index.v1+json"
+	dockerManifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+	dockerManifestMediaType     = "application/vnd.docker.distribution.manifest.v2+json"
+	ociLayerMediaType           = "application/vnd.oci.image.layer.v1.tar"
+	dockerLayerMediaType        = "application/vnd.docker.image.rootfs.diff.tar"
+)
+
+// ImageFormat define a safe type for image tarball formats
+type ImageFormat int
+
+const (
+	//ImageFormatDocker defines docker save tarballs (manifest.json)
+	ImageFormatDocker ImageFormat = 1
+
+	//ImageFormatOCI defines OCI image layout tarballs (index.json), with or without manifest.json
+	ImageFormatOCI ImageFormat = 2
+)
+
+func (f ImageFormat) String() string {
+	switch f {
+	case ImageFormatDocker:
+		return "image-format-docker"
+	case ImageFormatOCI:
+		return "image-format-oci"
+	default:
+		return "image-format-unknown"
+	}
+}
+
+// Image is a container image tarball, as produced by docker save or as an OCI image layout,
+// holding the effective /etc/hosts of the image.
+// only the first image of the tarball is handled
+type Image struct {
+	//HostsFile is the effective /etc/hosts of the image, empty if the image has none
+	HostsFile *HostsFile
+
+	// path of the image tarball
+	path string
+
+	// tarball format
+	format ImageFormat
+
+	// true if the whole tarball is gzip compressed
+	gzipped bool
+
+	// entry names found in the tarball
+	entries map[string]bool
+
+	// OCI index.json and image manifest
+	index        map[string]any
+	manifestName string
+	manifest     map[string]any
+
+	// docker save manifest.json, if any
+	dockerManifest []any
+
+	// image config
+	configName string
+	config     map[string]any
+
+	// layer entry names, from the bottom to the top layer
+	layers []string
+
+	// headers of the effective etc directory and etc/hosts file, if any
+	etcHeader   *tar.Header
+	hostsHeader *tar.Header
+}
+
+// imageLayerResult holds what a single layer does to a file
+type imageLayerResult struct {
+	// true if the layer removes the lower file (whiteouts, opaque directories)
+	deleted bool
+
+	// file header and content, if the layer sets it
+	file *tar.Header
+	data []byte
+
+	// etc directory header, if the layer sets it
+	etc *tar.Header
+}
+
+// OpenImage opens the image tarball at the given path and loads its effective /etc/hosts,
+// following layers from the bottom to the top, with whiteouts.
+// a symlinked /etc/hosts is resolved within the layers, it is loaded as an empty file
+// if its target is missing, and replaced by a regular file on Save.
+// options are applied to the loaded HostsFile, which uses DialectGlibc by default.
+// error is not nil if something goes wrong
+func OpenImage(path string, opts ...Option) (*Image, error) {
+	img := &Image{path: path}
+
+	data, err := img.load()
+	if err != nil {
+		return nil, err
+	}
+
+	img.HostsFile = New(append([]Option{WithDialect(DialectGlibc)}, opts...)...)
+
+	if err := img.HostsFile.load(context.Background(), data); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// Format returns the image tarball format
+func (img *Image) Format() ImageFormat {
+	return img.format
+}
+
+// Layers returns the layer entry names, from the bottom to the top layer
+func (img *Image) Layers() []string {
+	return append([]string{}, img.layers...)
+}
+
+// load reads the image metadata and returns the effective etc/hosts content
+func (img *Image) load() ([]byte, error) {
+	gzipped, err := isGzipFile(img.path)
+	if err != nil {
+		return nil, err
+	}
+	img.gzipped = gzipped
+
+	// 1st pass, top level metadata
+	img.entries = make(map[string]bool)
+	meta, err := img.readEntries(func(name string) bool {
+		img.entries[name] = true
+		return name == imageIndexName || name == imageManifestName || name == imageLayoutName
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if data, ok := meta[imageManifestName]; ok {
+		if err := json.Unmarshal(data, &img.dockerManifest); err != nil {
+			return nil, err
+		}
+
+		if len(img.dockerManifest) == 0 {
+			return nil, ErrUnsupportedImage("empty manifest.json")
+		}
+	}
+
+	switch {
+	case meta[imageIndexName] != nil:
+		img.format = ImageFormatOCI
+		err = img.loadOCI(meta[imageIndexName])
+	case img.dockerManifest != nil:
+		img.format = ImageFormatDocker
+		err = img.loadDocker()
+	default:
+		err = ErrUnsupportedImage("missing index.json and manifest.json")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return img.scanLayers()
+}
+
+// loadOCI reads the image manifest and config of an OCI image layout
+func (img *Image) loadOCI(indexData []byte) error {
+	if err := json.Unmarshal(indexData, &img.index); err != nil {
+		return err
+	}
+
+	manifests, _ := img.index["manifests"].([]any)
+	if len(manifests) == 0 {
+		return ErrUnsupportedImage("empty index.json")
+	}
+
+	desc, _ := manifests[0].(map[string]any)
+	if mt, _ := desc["mediaType"].(string); mt == ociImageIndexMediaType || mt == dockerManifestListMediaType {
+		return ErrUnsupportedImage("multi-platform images")
+	}
+
+	img.manifestName = blobName(desc)
+
+	blobs, err := img.readEntries(func(name string) bool {
+		return name == img.manifestName
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := unmarshalEntry(blobs, img.manifestName, &img.manifest); err != nil {
+		return err
+	}
+
+	config, _ := img.manifest["config"].(map[string]any)
+	img.configName = blobName(config)
+
+	layers, _ := img.manifest["layers"].([]any)
+	for _, l := range layers {
+		desc, _ := l.(map[string]any)
+		img.layers = append(img.layers, blobName(desc))
+	}
+
+	return img.loadConfig()
+}
+
+// loadDocker reads the image config of a docker save tarball
+func (img *Image) loadDocker() error {
+	entry, _ := img.dockerManifest[0].(map[string]any)
+
+	img.configName, _ = entry["Config"].(string)
+
+	layers, _ := entry["Layers"].([]any)
+	for _, l := range layers {
+		name, _ := l.(string)
+		img.layers = append(img.layers, cleanEntryName(name))
+	}
+
+	return img.loadConfig()
+}
+
+// loadConfig reads the image config
+func (img *Image) loadConfig() error {
+	img.configName = cleanEntryName(img.configName)
+
+	blobs, err := img.readEntries(func(name string) bool {
+		return name == img.configName
+	})
+	if err != nil {
+		return err
+	}
+
+	return unmarshalEntry(blobs, img.configName, &img.config)
+}
+
+// scanLayers reads every layer and returns the effective etc/hosts content,
+// following symlinks and hard links within the layers
+func (img *Image) scanLayers() ([]byte, error) {
+	name := imageHostsName
+
+	for links := 0; links <= maxSymlinks; links++ {
+		hdr, data, etc, err := img.scanFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if name == imageHostsName {
+			img.etcHeader = etc
+		}
+
+		switch {
+		case hdr == nil:
+			// missing, or dangling link
+			img.hostsHeader = nil
+			return nil, nil
+		case hdr.Typeflag == tar.TypeSymlink:
+			name = cleanEntryName(path.Join(path.Dir(name), hdr.Linkname))
+			if path.IsAbs(hdr.Linkname) {
+				name = cleanEntryName(hdr.Linkname)
+			}
+		case hdr.Typeflag == tar.TypeLink:
+			name = cleanEntryName(hdr.Linkname)
+		default:
+			img.hostsHeader = hdr
+			return data, nil
+		}
+	}
+
+	return nil, &os.PathError{Op: "open", Path: "/" + imageHostsName, Err: ErrTooManySymlinks}
+}
+
+// scanFile reads every layer and returns the effective header and content of the named file,
+// and the effective etc directory header
+func (img *Image) scanFile(file string) (*tar.Header, []byte, *tar.Header, error) {
+	results := make(map[string]imageLayerResult)
+
+	wanted := make(map[string]bool)
+	for _, l := range img.layers {
+		wanted[l] = true
+	}
+
+	err := img.walk(func(hdr *tar.Header, r io.Reader) error {
+		name := cleanEntryName(hdr.Name)
+		if !wanted[name] {
+			return nil
+		}
+
+		res, err := scanImageLayer(r, file)
+		if err != nil {
+			return err
+		}
+
+		results[name] = res
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var hdr, etc *tar.Header
+	var data []byte
+
+	for _, l := range img.layers {
+		res, ok := results[l]
+		if !ok {
+			return nil, nil, nil, ErrUnsupportedImage("missing layer " + l)
+		}
+
+		// whiteouts remove lower layers content
+		if res.deleted {
+			hdr = nil
+			data = nil
+		}
+
+		if res.file != nil {
+			hdr = res.file
+			data = res.data
+		}
+
+		if res.etc != nil {
+			etc = res.etc
+		}
+	}
+
+	return hdr, data, etc, nil
+}
+
+// scanImageLayer reads a single, possibly gzip compressed, layer looking for the named file
+func scanImageLayer(r io.Reader, file string) (imageLayerResult, error) {
+	var res imageLayerResult
+
+	whiteouts := fileWhiteouts(file)
+
+	lr, err := decompress(r)
+	if err != nil {
+		return res, err
+	}
+
+	tr := tar.NewReader(lr)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return res, err
+		}
+
+		name := cleanEntryName(hdr.Name)
+
+		switch {
+		case whiteouts[name]:
+			res.deleted = true
+		case name == imageEtcName:
+			if hdr.Typeflag == tar.TypeDir {
+				res.etc = hdr
+			}
+		case name == file:
+			res.file = hdr
+			res.data = nil
+
+			// only regular files have content, links are followed by scanLayers
+			if hdr.Typeflag == tar.TypeReg {
+				res.data, err = io.ReadAll(tr)
+				if err != nil {
+					return res, err
+				}
+			}
+		}
+	}
+}
+
+// fileWhiteouts returns the whiteout entries removing the named file from lower layers:
+// whiteouts of the file or of any parent directory, and opaque parent directories
+func fileWhiteouts(file string) map[string]bool {
+	res := make(map[string]bool)
+
+	for name := file; name != "."; name = path.Dir(name) {
+		dir := path.Dir(name)
+
+		res[path.Join(dir, ".wh."+path.Base(name))] = true
+		res[path.Join(dir, ".wh..wh..opq")] = true
+	}
+
+	return res
+}
+
+// Save writes the image tarball to the given path, with the HostsFile as a new top layer.
+// image config, manifests and index are updated with the new digests.
+// path can be the path the image has been opened from.
+// error is not nil if something goes wrong
+func (img *Image) Save(path string) error {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	layer, err := img.buildLayer(now)
+	if err != nil {
+		return err
+	}
+
+	layerDigest := digestOf(layer)
+
+	// updated metadata, entry name and content
+	blobs := make([]imageBlob, 0)
+
+	// new layer and image config
+	layerName := img.newBlobName(layerDigest, "layer.tar")
+	blobs = append(blobs, imageBlob{name: layerName, data: layer})
+
+	appendJSONArray(img.config, []string{"rootfs", "diff_ids"}, layerDigest)
+	appendJSONArray(img.config, []string{"history"}, map[string]any{
+		"created":    now.Format(time.RFC3339),
+		"created_by": "libhosty",
+		"comment":    "update " + "/" + imageHostsName,
+	})
+
+	config, err := json.Marshal(img.config)
+	if err != nil {
+		return err
+	}
+
+	configDigest := digestOf(config)
+	configName := img.newBlobName(configDigest, "json")
+	blobs = append(blobs, imageBlob{name: configName, data: config})
+
+	replaced := make(map[string]bool)
+
+	if img.format == ImageFormatOCI {
+		layerMediaType := ociLayerMediaType
+		if mt, _ := img.manifest["mediaType"].(string); mt == dockerManifestMediaType {
+			layerMediaType = dockerLayerMediaType
+		}
+
+		configDesc, _ := img.manifest["config"].(map[string]any)
+		configDesc["digest"] = configDigest
+		configDesc["size"] = len(config)
+
+		appendJSONArray(img.manifest, []string{"layers"}, map[string]any{
+			"mediaType": layerMediaType,
+			"digest":    layerDigest,
+			"size":      len(layer),
+		})
+
+		manifest, err := json.Marshal(img.manifest)
+		if err != nil {
+			return err
+		}
+
+		manifestDigest := digestOf(manifest)
+		img.manifestName = img.newBlobName(manifestDigest, "json")
+		blobs = append(blobs, imageBlob{name: img.manifestName, data: manifest})
+
+		manifests, _ := img.index["manifests"].([]any)
+		desc, _ := manifests[0].(map[string]any)
+		desc["digest"] = manifestDigest
+		desc["size"] = len(manifest)
+
+		index, err := json.Marshal(img.index)
+		if err != nil {
+			return err
+		}
+
+		blobs = append(blobs, imageBlob{name: imageIndexName, data: index})
+		replaced[imageIndexName] = true
+	}
+
+	if img.dockerManifest != nil {
+		entry, _ := img.dockerManifest[0].(map[string]any)
+		entry["Config"] = configName
+		entry["Layers"] = append(jsonArray(entry["Layers"]), layerName)
+
+		manifest, err := json.Marshal(img.dockerManifest)
+		if err != nil {
+			return err
+		}
+
+		blobs = append(blobs, imageBlob{name: imageManifestName, data: manifest})
+		replaced[imageManifestName] = true
+	}
+
+	if err := img.write(path, blobs, replaced, now); err != nil {
+		return err
+	}
+
+	// the saved image is the new base for further saves
+	for _, b := range blobs {
+		img.entries[b.name] = true
+	}
+	img.path = path
+	img.configName = configName
+	img.layers = append(img.layers, layerName)
+
+	return nil
+}
+
+// imageBlob holds an entry added to the image tarball
+type imageBlob struct {
+	name string
+	data []byte
+}
+
+// buildLayer returns an uncompressed layer holding the rendered HostsFile
+func (img *Image) buildLayer(now time.Time) ([]byte, error) {
+	data := []byte(img.HostsFile.RenderHostsFile())
+
+	etc := &tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     imageEtcName + "/",
+		Mode:     0755,
+		ModTime:  now,
+	}
+	if img.etcHeader != nil {
+		etc.Mode = img.etcHeader.Mode
+		etc.Uid = img.etcHeader.Uid
+		etc.Gid = img.etcHeader.Gid
+		etc.Uname = img.etcHeader.Uname
+		etc.Gname = img.etcHeader.Gname
+		etc.ModTime = img.etcHeader.ModTime
+	}
+
+	hosts := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     imageHostsName,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  now,
+	}
+	// links are replaced by a regular file, which does not inherit their mode
+	if img.hostsHeader != nil && img.hostsHeader.Typeflag == tar.TypeReg {
+		hosts.Mode = img.hostsHeader.Mode
+		hosts.Uid = img.hostsHeader.Uid
+		hosts.Gid = img.hostsHeader.Gid
+		hosts.Uname = img.hostsHeader.Uname
+		hosts.Gname = img.hostsHeader.Gname
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	if err := tw.WriteHeader(etc); err != nil {
+		return nil, err
+	}
+
+	if err := tw.WriteHeader(hosts); err != nil {
+		return nil, err
+	}
+
+	if _, err := tw.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// newBlobName returns the entry name for a new blob with the given digest.
+// OCI layouts use blobs/<algorithm>/<hex>, docker save tarballs use <hex>/layer.tar and <hex>.json
+func (img *Image) newBlobName(digest, kind string) string {
+	if img.format == ImageFormatOCI {
+		return blobName(map[string]any{"digest": digest})
+	}
+
+	hexDigest := strings.TrimPrefix(digest, "sha256:")
+	if kind == "json" {
+		return hexDigest + ".json"
+	}
+
+	return hexDigest + "/" + kind
+}
+
+// write copies the image tarball to dst, skipping replaced entries and appending blobs.
+// the tarball is written to a temporary file first and then renamed
+func (img *Image) write(dst string, blobs []imageBlob, replaced map[string]bool, now time.Time) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".libhosty-image-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	bw := bufio.NewWriter(tmp)
+
+	var w io.Writer = bw
+	var gw *gzip.Writer
+	if img.gzipped {
+		gw = gzip.NewWriter(bw)
+		w = gw
+	}
+
+	tw := tar.NewWriter(w)
+
+	err = img.walk(func(hdr *tar.Header, r io.Reader) error {
+		if replaced[cleanEntryName(hdr.Name)] {
+			return nil
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		_, err := io.Copy(tw, r)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	dirs := make(map[string]bool)
+
+	for _, b := range blobs {
+		// blobs with the same digest already exist
+		if img.entries[b.name] && !replaced[b.name] {
+			continue
+		}
+
+		// parent directories, for docker save layers
+		if dir := path.Dir(b.name); dir != "." && !img.entries[dir] && !dirs[dir] {
+			dirs[dir] = true
+
+			if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: dir + "/", Mode: 0755, ModTime: now}); err != nil {
+				return err
+			}
+		}
+
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: b.name, Mode: 0644, Size: int64(len(b.data)), ModTime: now}); err != nil {
+			return err
+		}
+
+		if _, err := tw.Write(b.data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if gw != nil {
+		if err := gw.Close(); err != nil {
+			return err
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
+
+// readEntries returns the content of every entry accepted by match
+func (img *Image) readEntries(match func(name string) bool) (map[string][]byte, error) {
+	res := make(map[string][]byte)
+
+	err := img.walk(func(hdr *tar.Header, r io.Reader) error {
+		name := cleanEntryName(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || !match(name) {
+			return nil
+		}
+
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		res[name] = data
+		return nil
+	})
+
+	return res, err
+}
+
+// walk calls fn for every entry of the image tarball
+func (img *Image) walk(fn func(hdr *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(img.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if img.gzipped {
+		gr, err := gzip.NewReader(bufio.NewReader(f))
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+
+		r = gr
+	}
+
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// isGzipFile reports whether the file at path is gzip compressed
+func isGzipFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false, err
+	}
+
+	return magic[0] == 0x1f && magic[1] == 0x8b, nil
+}
+
+// decompress returns a reader for a possibly gzip compressed stream
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return nil, ErrUnsupportedImage("zstd compressed layers")
+	default:
+		return br, nil
+	}
+}
+
+// blobName returns the OCI layout entry name of the given descriptor
+func blobName(desc map[string]any) string {
+	digest, _ := desc["digest"].(string)
+	algorithm, hexDigest, _ := strings.Cut(digest, ":")
+
+	return path.Join("blobs", algorithm, hexDigest)
+}
+
+// cleanEntryName normalizes tar entry names, without leading ./ or / and trailing /
+func cleanEntryName(name string) string {
+	name = path.Clean("/" + name)
+	return strings.TrimPrefix(name, "/")
+}
+
+// digestOf returns the sha256 digest of data, in the sha256:<hex> form
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// unmarshalEntry decodes the named JSON entry
+func unmarshalEntry(entries map[string][]byte, name string, v any) error {
+	data, ok := entries[name]
+	if !ok {
+		return ErrUnsupportedImage("missing " + name)
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// jsonArray returns v as a JSON array, empty if v is not an array
+func jsonArray(v any) []any {
+	a, _ := v.([]any)
+	return a
+}
+
+// appendJSONArray appends value to the array found following keys, creating missing objects
+func appendJSONArray(obj map[string]any, keys []string, value any) {
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := obj[k].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			obj[k] = next
+		}
+
+		obj = next
+	}
+
+	last := keys[len(keys)-1]
+	obj[last] = append(jsonArray(obj[last]), value)
+}
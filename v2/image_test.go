@@ -0,0 +1,351 @@
+package libhosty
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testTarEntry holds an entry of a synthetic tarball
+type testTarEntry struct {
+	name     string
+	data     string
+	typeflag byte
+	linkname string
+}
+
+func buildTestTar(t *testing.T, entries []testTarEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	for _, e := range entries {
+		typeflag := e.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+
+		hdr := &tar.Header{Typeflag: typeflag, Name: e.name, Linkname: e.linkname, Mode: 0644, Size: int64(len(e.data))}
+		if typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+
+		if typeflag == tar.TypeSymlink {
+			hdr.Mode = 0777
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		if typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.data)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func gzipTestData(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+
+	if _, err := gw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func writeTestImage(t *testing.T, data []byte) string {
+	t.Helper()
+
+	p := filepath.Join(t.TempDir(), "image.tar")
+	if err := os.WriteFile(p, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestOpenImageDocker(t *testing.T) {
+	base := buildTestTar(t, []testTarEntry{
+		{name: "etc/", typeflag: tar.TypeDir},
+		{name: "etc/hosts", data: "127.0.0.1 localhost\n"},
+	})
+	top := buildTestTar(t, []testTarEntry{
+		{name: "etc/hosts", data: "127.0.0.1 localhost\n10.0.0.1 db\n"},
+	})
+
+	config := mustJSON(t, map[string]any{"rootfs": map[string]any{"type": "layers", "diff_ids": []string{digestOf(base), digestOf(top)}}})
+	manifest := mustJSON(t, []any{map[string]any{"Config": "config.json", "RepoTags": []string{"test:latest"}, "Layers": []string{"base/layer.tar", "top/layer.tar"}}})
+
+	p := writeTestImage(t, buildTestTar(t, []testTarEntry{
+		{name: "base/layer.tar", data: string(base)},
+		{name: "top/layer.tar", data: string(top)},
+		{name: "config.json", data: config},
+		{name: "manifest.json", data: manifest},
+	}))
+
+	img, err := OpenImage(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Format() != ImageFormatDocker {
+		t.Fatalf("expected %s, got %s", ImageFormatDocker, img.Format())
+	}
+
+	if _, ip, err := img.HostsFile.LookupByHostname("db"); err != nil || ip.String() != "10.0.0.1" {
+		t.Fatalf("expected db from the top layer, got %v %v", ip, err)
+	}
+
+	if _, _, err := img.HostsFile.AddHostsFileLine("10.0.0.2", "cache", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := img.Save(p); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := OpenImage(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ip, err := saved.HostsFile.LookupByHostname("cache"); err != nil || ip.String() != "10.0.0.2" {
+		t.Fatalf("expected cache in the saved image, got %v %v", ip, err)
+	}
+
+	if len(saved.Layers()) != 3 {
+		t.Fatalf("expected 3 layers, got %v", saved.Layers())
+	}
+
+	diffIDs := jsonArray(saved.config["rootfs"].(map[string]any)["diff_ids"])
+	if len(diffIDs) != 3 {
+		t.Fatalf("expected 3 diff_ids, got %v", diffIDs)
+	}
+
+	tags := jsonArray(saved.dockerManifest[0].(map[string]any)["RepoTags"])
+	if len(tags) != 1 || tags[0] != "test:latest" {
+		t.Fatalf("expected RepoTags to be preserved, got %v", tags)
+	}
+}
+
+func TestOpenImageOCI(t *testing.T) {
+	base := gzipTestData(t, buildTestTar(t, []testTarEntry{
+		{name: "./etc/hosts", data: "127.0.0.1 localhost\n"},
+	}))
+	top := buildTestTar(t, []testTarEntry{
+		{name: "etc/.wh.hosts", data: ""},
+	})
+
+	config := mustJSON(t, map[string]any{"rootfs": map[string]any{"type": "layers", "diff_ids": []string{"sha256:a", "sha256:b"}}})
+	manifest := mustJSON(t, map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config":        map[string]any{"mediaType": "application/vnd.oci.image.config.v1+json", "digest": digestOf([]byte(config)), "size": len(config)},
+		"layers": []any{
+			map[string]any{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": digestOf(base), "size": len(base)},
+			map[string]any{"mediaType": ociLayerMediaType, "digest": digestOf(top), "size": len(top)},
+		},
+	})
+	index := mustJSON(t, map[string]any{
+		"schemaVersion": 2,
+		"manifests": []any{map[string]any{
+			"mediaType":   "application/vnd.oci.image.manifest.v1+json",
+			"digest":      digestOf([]byte(manifest)),
+			"size":        len(manifest),
+			"annotations": map[string]any{"org.opencontainers.image.ref.name": "latest"},
+		}},
+	})
+
+	blob := func(data string) string {
+		return "blobs/sha256/" + strings.TrimPrefix(digestOf([]byte(data)), "sha256:")
+	}
+
+	p := writeTestImage(t, gzipTestData(t, buildTestTar(t, []testTarEntry{
+		{name: "oci-layout", data: `{"imageLayoutVersion":"1.0.0"}`},
+		{name: "blobs/", typeflag: tar.TypeDir},
+		{name: "blobs/sha256/", typeflag: tar.TypeDir},
+		{name: blob(string(base)), data: string(base)},
+		{name: blob(string(top)), data: string(top)},
+		{name: blob(config), data: config},
+		{name: blob(manifest), data: manifest},
+		{name: "index.json", data: index},
+	})))
+
+	img, err := OpenImage(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Format() != ImageFormatOCI {
+		t.Fatalf("expected %s, got %s", ImageFormatOCI, img.Format())
+	}
+
+	// the top layer removes etc/hosts
+	if len(img.HostsFile.GetHostsFileLines()) != 0 {
+		t.Fatalf("expected no lines, got %v", img.HostsFile.GetHostsFileLines())
+	}
+
+	if _, _, err := img.HostsFile.AddHostsFileLine("10.0.0.1", "db", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), "out.tar")
+	if err := img.Save(out); err != nil {
+		t.Fatal(err)
+	}
+
+	if gz, err := isGzipFile(out); err != nil || !gz {
+		t.Fatalf("expected a gzip compressed tarball, got %v %v", gz, err)
+	}
+
+	saved, err := OpenImage(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ip, err := saved.HostsFile.LookupByHostname("db"); err != nil || ip.String() != "10.0.0.1" {
+		t.Fatalf("expected db in the saved image, got %v %v", ip, err)
+	}
+
+	// digests of the new manifest and config must match their content
+	blobs, err := saved.readEntries(func(name string) bool {
+		return name == saved.manifestName || name == saved.configName
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	desc := jsonArray(saved.index["manifests"])[0].(map[string]any)
+	if desc["digest"] != digestOf(blobs[saved.manifestName]) {
+		t.Fatalf("index digest %v does not match the manifest", desc["digest"])
+	}
+
+	if desc["annotations"] == nil {
+		t.Fatal("expected index annotations to be preserved")
+	}
+
+	if saved.manifest["config"].(map[string]any)["digest"] != digestOf(blobs[saved.configName]) {
+		t.Fatal("manifest config digest does not match the config")
+	}
+
+	layers := jsonArray(saved.manifest["layers"])
+	diffIDs := jsonArray(saved.config["rootfs"].(map[string]any)["diff_ids"])
+	if len(layers) != 3 || len(diffIDs) != 3 || layers[2].(map[string]any)["digest"] != diffIDs[2] {
+		t.Fatalf("expected a new uncompressed layer, got %v %v", layers, diffIDs)
+	}
+}
+
+func TestOpenImageSymlink(t *testing.T) {
+	base := buildTestTar(t, []testTarEntry{
+		{name: "etc/", typeflag: tar.TypeDir},
+		{name: "etc/hosts", typeflag: tar.TypeSymlink, linkname: "../run/hosts"},
+		{name: "run/", typeflag: tar.TypeDir},
+		{name: "run/hosts", data: "10.0.0.9 stale\n"},
+	})
+	top := buildTestTar(t, []testTarEntry{
+		{name: "run/hosts", data: "127.0.0.1 localhost\n10.0.0.1 db\n"},
+	})
+
+	config := mustJSON(t, map[string]any{"rootfs": map[string]any{"type": "layers", "diff_ids": []string{digestOf(base), digestOf(top)}}})
+	manifest := mustJSON(t, []any{map[string]any{"Config": "config.json", "Layers": []string{"base/layer.tar", "top/layer.tar"}}})
+
+	p := writeTestImage(t, buildTestTar(t, []testTarEntry{
+		{name: "base/layer.tar", data: string(base)},
+		{name: "top/layer.tar", data: string(top)},
+		{name: "config.json", data: config},
+		{name: "manifest.json", data: manifest},
+	}))
+
+	img, err := OpenImage(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the link target is read from the top layer
+	if _, ip, err := img.HostsFile.LookupByHostname("db"); err != nil || ip.String() != "10.0.0.1" {
+		t.Fatalf("expected db from the link target, got %v %v", ip, err)
+	}
+
+	if err := img.Save(p); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := OpenImage(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	top = nil
+	err = saved.walk(func(hdr *tar.Header, r io.Reader) error {
+		if cleanEntryName(hdr.Name) == saved.layers[len(saved.layers)-1] {
+			top, err = io.ReadAll(r)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := tar.NewReader(bytes.NewReader(top))
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatalf("expected etc/hosts in the new layer, got %v", err)
+		}
+
+		if hdr.Name != imageHostsName {
+			continue
+		}
+
+		// the link is replaced by a regular file, without its mode
+		if hdr.Typeflag != tar.TypeReg || hdr.Mode != 0644 {
+			t.Fatalf("expected a 0644 regular file, got type %c mode %o", hdr.Typeflag, hdr.Mode)
+		}
+
+		break
+	}
+}
+
+func TestOpenImageUnsupported(t *testing.T) {
+	p := writeTestImage(t, buildTestTar(t, []testTarEntry{
+		{name: "etc/hosts", data: "127.0.0.1 localhost\n"},
+	}))
+
+	if _, err := OpenImage(p); err == nil {
+		t.Fatal("expected an error for a tarball without manifests")
+	}
+}
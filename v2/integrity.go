@@ -0,0 +1,133 @@
+package libhosty
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// integrityPrefix starts the integrity header comment, after the hashes
+const integrityPrefix = "libhosty-integrity:"
+
+// WithIntegrity makes writes maintain an integrity header, a comment on the first line
+// holding the sha256 digest of the rest of the file and, if key is not empty,
+// its HMAC-SHA256 with key. Verify checks the header.
+// the header is not part of HostsFileLines, it is stripped when the file is loaded or reloaded
+func WithIntegrity(key []byte) Option {
+	return func(h *HostsFile) {
+		h.integrity = true
+		h.integrityKey = append([]byte{}, key...)
+	}
+}
+
+// Verify checks the integrity header of the hosts file as it was last read from or written to Path,
+// to detect edits made since the last managed write.
+// error is ErrIntegrityHeaderNotFound if there is no header,
+// ErrIntegrityMismatch if the content does not match it
+func (h *HostsFile) Verify() error {
+	h.Lock()
+	data := h.loaded
+	key := h.integrityKey
+	h.Unlock()
+
+	return VerifyIntegrity(data, key)
+}
+
+// VerifyIntegrity checks the integrity header of the given hosts file content.
+// if key is not empty the header must hold a matching HMAC too.
+// error is ErrIntegrityHeaderNotFound if there is no header,
+// ErrIntegrityMismatch if the content does not match it
+func VerifyIntegrity(data, key []byte) error {
+	header, content, found := cutIntegrityHeader(data)
+	if !found {
+		return ErrIntegrityHeaderNotFound
+	}
+
+	fields := integrityFields(header)
+	expected := integrityFields(integrityHeader(content, key))
+
+	if !hmac.Equal([]byte(fields["sha256"]), []byte(expected["sha256"])) {
+		return ErrIntegrityMismatch
+	}
+
+	if len(key) > 0 && !hmac.Equal([]byte(fields["hmac"]), []byte(expected["hmac"])) {
+		return ErrIntegrityMismatch
+	}
+
+	return nil
+}
+
+// sealIntegrity returns data with its integrity header replaced by a new one, on the first line
+func sealIntegrity(data, key []byte, lineEnding string) []byte {
+	content := integrityContent(data)
+
+	res := make([]byte, 0, len(content)+128)
+	res = append(res, integrityHeader(content, key)...)
+	res = append(res, lineEnding...)
+
+	return append(res, content...)
+}
+
+// integrityContent returns data without its integrity header, if any
+func integrityContent(data []byte) []byte {
+	if _, content, found := cutIntegrityHeader(data); found {
+		return content
+	}
+
+	return data
+}
+
+// integrityHeader returns the integrity header line of content, without line ending
+func integrityHeader(content, key []byte) string {
+	sum := sha256.Sum256(content)
+	header := "# " + integrityPrefix + " sha256=" + hex.EncodeToString(sum[:])
+
+	if len(key) > 0 {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(content)
+		header += " hmac=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	return header
+}
+
+// cutIntegrityHeader returns the integrity header of data and data without it.
+// the header is only accepted on the first line, matching comments elsewhere are content
+func cutIntegrityHeader(data []byte) (string, []byte, bool) {
+	line, content, found := bytes.Cut(data, []byte("\n"))
+	if !found {
+		content = nil
+	}
+
+	header := string(bytes.TrimSpace(line))
+	if !isIntegrityHeader(header) {
+		return "", nil, false
+	}
+
+	return header, content, true
+}
+
+// isIntegrityHeader reports whether the trimmed line is an integrity header
+func isIntegrityHeader(line string) bool {
+	if !strings.HasPrefix(line, "#") {
+		return false
+	}
+
+	return strings.HasPrefix(strings.TrimSpace(strings.TrimLeft(line, "#")), integrityPrefix)
+}
+
+// integrityFields returns the key=value fields of an integrity header
+func integrityFields(header string) map[string]string {
+	fields := make(map[string]string)
+
+	_, values, _ := strings.Cut(header, integrityPrefix)
+	for _, f := range strings.Fields(values) {
+		if k, v, ok := strings.Cut(f, "="); ok {
+			fields[k] = v
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,169 @@
+package libhosty
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestIntegrity(t *testing.T) {
+	mfs := NewMemFS()
+	if err := mfs.WriteFile("/etc/hosts", []byte("127.0.0.1 localhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	key := []byte("secret")
+
+	h, err := Open(context.Background(), "/etc/hosts", WithFS(mfs), WithDialect(DialectGlibc), WithIntegrity(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Verify(); err != ErrIntegrityHeaderNotFound {
+		t.Fatalf("expected ErrIntegrityHeaderNotFound, got %v", err)
+	}
+
+	if _, _, err := h.AddHostsFileLine("10.0.0.1", "db", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.WriteHostsFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a second write keeps a single header
+	if err := h.WriteHostsFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := mfs.ReadFile("/etc/hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(string(data), "# "+integrityPrefix+" sha256=") || strings.Count(string(data), integrityPrefix) != 1 {
+		t.Fatalf("unexpected content %q", data)
+	}
+
+	reopened, err := Open(context.Background(), "/etc/hosts", WithFS(mfs), WithIntegrity(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reopened.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// manual edit
+	tampered := strings.Replace(string(data), "10.0.0.1", "10.6.6.6", 1)
+	if err := VerifyIntegrity([]byte(tampered), key); err != ErrIntegrityMismatch {
+		t.Fatalf("expected ErrIntegrityMismatch, got %v", err)
+	}
+
+	// the digest alone can be recomputed, the HMAC cannot
+	resealed := sealIntegrity([]byte(tampered), nil, "\n")
+	if err := VerifyIntegrity(resealed, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyIntegrity(resealed, key); err != ErrIntegrityMismatch {
+		t.Fatalf("expected ErrIntegrityMismatch, got %v", err)
+	}
+}
+
+func TestIntegrityCRLF(t *testing.T) {
+	mfs := NewMemFS()
+
+	h := New(WithFS(mfs), WithDialect(DialectWindows), WithIntegrity(nil))
+	if _, _, err := h.AddHostsFileLine("10.0.0.1", "db", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.WriteHostsFileTo("/hosts"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := mfs.ReadFile("/hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyIntegrity(data, nil); err != nil {
+		t.Fatalf("unexpected error %v for %q", err, data)
+	}
+}
+
+func TestIntegrityReload(t *testing.T) {
+	mfs := NewMemFS()
+	if err := mfs.WriteFile("/etc/hosts", []byte("127.0.0.1 localhost\n10.0.0.1 db\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := Open(context.Background(), "/etc/hosts", WithFS(mfs), WithDialect(DialectGlibc), WithIntegrity(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.WriteHostsFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := len(h.HostsFileLines)
+
+	// an external tool reseals the file after its own edit
+	data, err := mfs.ReadFile("/etc/hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited := strings.Replace(string(data), "10.0.0.1", "10.0.0.2", 1)
+	if err := mfs.WriteFile("/etc/hosts", sealIntegrity([]byte(edited), nil, "\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := h.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the header is not a line, rows are unchanged
+	if len(h.HostsFileLines) != rows || h.HostsFileLines[1].Address.String() != "10.0.0.2" {
+		t.Fatalf("unexpected lines %v", h.HostsFileLines)
+	}
+
+	if err := h.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(context.Background(), "/etc/hosts", WithFS(mfs), WithIntegrity(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reopened.HostsFileLines) != rows {
+		t.Fatalf("unexpected lines %v", reopened.HostsFileLines)
+	}
+}
+
+func TestIntegrityHeaderFirstLineOnly(t *testing.T) {
+	sealed := sealIntegrity([]byte("127.0.0.1 localhost\n"), nil, "\n")
+
+	// a header below the first line is content, it does not validate the file
+	moved := append([]byte("10.6.6.6 bank.com\n"), sealed...)
+	if err := VerifyIntegrity(moved, nil); err != ErrIntegrityHeaderNotFound {
+		t.Fatalf("expected ErrIntegrityHeaderNotFound, got %v", err)
+	}
+
+	// and it is kept when sealing
+	resealed := sealIntegrity(moved, nil, "\n")
+	if strings.Count(string(resealed), integrityPrefix) != 2 {
+		t.Fatalf("unexpected content %q", resealed)
+	}
+
+	if err := VerifyIntegrity(resealed, nil); err != nil {
+		t.Fatal(err)
+	}
+}
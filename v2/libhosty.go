@@ -2,12 +2,13 @@
 package libhosty
 
 import (
+	"context"
+	"errors"
 	"net"
 	"regexp"
 	"strings"
 	"sync"
-
-	"golang.org/x/exp/slices"
+	"time"
 )
 
 const (
@@ -17,6 +18,9 @@ const (
 	// defines default path for linux os
 	unixFilePath = "/etc/"
 
+	// defines default path for android os
+	androidFilePath = "/system/etc/"
+
 	// defines default filename
 	hostsFileName = "hosts"
 )
@@ -59,17 +63,27 @@ type HostsFileLine struct {
 	//Address is a net.IP representation of the address
 	Address net.IP
 
+	//Zone is the IPv6 zone of the address, if any (fe80::1%lo0)
+	Zone string
+
 	//Hostnames is a slice of hostnames for the relative IP
 	Hostnames []string
 
 	//Raw is the raw representation of the line, as it is in the hosts file
 	Raw string
 
-	//Comment is the comment part of the line (if present in an ADDRESS line)
+	//Comment is the comment part of the line (if present in an ADDRESS line), without annotations
 	Comment string
 
+	//Annotations are the key=value pairs following "libhosty:" in the comment of an ADDRESS line,
+	//nil if there are none
+	Annotations map[string]string
+
 	//IsCommented to know if the current ADDRESS line is commented out (starts with '#')
 	IsCommented bool
+
+	// untrimmed is the line as parsed, before TrimSpace, used by Audit
+	untrimmed string
 }
 
 // HostsFile is a reference for the hosts file configuration and lines
@@ -81,68 +95,82 @@ type HostsFile struct {
 
 	//HostsFileLines slice of HostsFileLine objects
 	HostsFileLines []HostsFileLine
-}
 
-// Init returns a new instance of a hostsfile.
-func Init() (*HostsFile, error) {
-	// get hosts file default path
-	fpath := GetOSHostsFilePath()
+	//MaxHostnamesPerLine is the maximum number of hostnames on a single address line.
+	//0 means DefaultMaxHostnamesPerLine
+	MaxHostnamesPerLine int
 
-	// parse hosts file from default path
-	hostsFileLines, err := ParseHostsFile(fpath)
-	if err != nil {
-		return nil, err
-	}
+	//MaxLineLength is the maximum length of a rendered address line.
+	//0 means unlimited
+	MaxLineLength int
 
-	// allocate a new HostsFile object
-	hf := &HostsFile{
-		// use default configuration
-		Path: fpath,
+	//Dialect defines the resolver the hosts file is meant for.
+	//the zero value means the dialect of the running system
+	Dialect Dialect
 
-		// allocate a new slice of HostsFileLine objects
-		HostsFileLines: hostsFileLines,
-	}
+	//Preserve defines how lines are rendered
+	Preserve PreservationMode
 
-	//return HostsFile
-	return hf, nil
+	// strict makes the parser fail on unknown lines
+	strict bool
+
+	// lockTimeout is the maximum wait for the hosts file lock, 0 disables locking
+	lockTimeout time.Duration
+
+	// fs is the filesystem used to read and write the hosts file, nil means OSFS
+	fs FS
+
+	// newFile holds the mode and owner of hosts files created by writes
+	newFile fileAttrs
+
+	// base holds the lines as they were last read from or written to Path, for Reload
+	base []HostsFileLine
+
+	// loaded holds the content as it was last read from or written to Path, for Verify
+	loaded []byte
+
+	// integrity makes writes maintain the integrity header, signed with integrityKey if any
+	integrity    bool
+	integrityKey []byte
+
+	// hooks are called around every mutation
+	hooks []Hook
+
+	// auditPending holds the mutations recorded by each AuditLog, until the next write
+	auditPending map[*AuditLog][]AuditOperation
 }
 
-func InitFromCustomPath(path string) (*HostsFile, error) {
-	// parse hosts file from default path
-	hostsFileLines, err := ParseHostsFile(path)
-	if err != nil {
-		return nil, err
-	}
+// Init returns a new instance of a hostsfile.
+func Init() (*HostsFile, error) {
+	return Open(context.Background(), GetOSHostsFilePath())
+}
 
-	// allocate a new HostsFile object
-	hf := &HostsFile{
-		// use default configuration
-		Path: path,
+// InitWithDialect returns a new instance of a hostsfile for the given dialect,
+// loaded from the dialect default path
+func InitWithDialect(d Dialect) (*HostsFile, error) {
+	return Open(context.Background(), d.HostsFilePath, WithDialect(d))
+}
 
-		// allocate a new slice of HostsFileLine objects
-		HostsFileLines: hostsFileLines,
-	}
+// InitFromCustomPath returns a new instance of a hostsfile loaded from the given path
+func InitFromCustomPath(path string) (*HostsFile, error) {
+	return Open(context.Background(), path)
+}
 
-	//return HostsFile
-	return hf, nil
+// InitFromCustomPathWithDialect returns a new instance of a hostsfile for the given dialect,
+// loaded from the given path
+func InitFromCustomPathWithDialect(path string, d Dialect) (*HostsFile, error) {
+	return Open(context.Background(), path, WithDialect(d))
 }
 
+// InitFromString returns a new instance of a hostsfile parsed from the given string
 func InitFromString(lines string) (*HostsFile, error) {
+	hf := New()
+
 	// parse inline hosts file
-	hostsFileLines, err := ParseHostsFileFromString(lines)
-	if err != nil {
+	if err := hf.load(context.Background(), []byte(lines)); err != nil {
 		return nil, err
 	}
 
-	// allocate a new HostsFile object
-	hf := &HostsFile{
-		// use default configuration
-		Path: "",
-
-		// allocate a new slice of HostsFileLine objects
-		HostsFileLines: hostsFileLines,
-	}
-
 	//return HostsFile
 	return hf, nil
 }
@@ -222,23 +250,42 @@ func (h *HostsFile) GetHostsFileLinesByRegexp(pattern string) []HostsFileLine {
 	return hfl
 }
 
+// GetHostsFileLinesBySelector returns every line that matches a given Selector
+func (h *HostsFile) GetHostsFileLinesBySelector(sel Selector) []*HostsFileLine {
+	hfl := make([]*HostsFileLine, 0)
+
+	for idx := range h.HostsFileLines {
+		if sel(idx, &h.HostsFileLines[idx]) {
+			hfl = append(hfl, &h.HostsFileLines[idx])
+		}
+	}
+
+	return hfl
+}
+
+// GetHostsFileLinesByDomain returns every line with a hostname equal to, or under, the given domain
+func (h *HostsFile) GetHostsFileLinesByDomain(domain string) []*HostsFileLine {
+	return h.GetHostsFileLinesBySelector(SelectDomain(domain))
+}
+
+// GetHostsFileLinesByGlob returns every line with a hostname that matches a given glob pattern
+func (h *HostsFile) GetHostsFileLinesByGlob(pattern string) []*HostsFileLine {
+	return h.GetHostsFileLinesBySelector(SelectGlob(pattern))
+}
+
 // RemoveHostsFileLineByRow remove row at given index from HostsFileLines
 func (h *HostsFile) RemoveHostsFileLineByRow(row int) {
 	// prevent out-of-index
-	if row < len(h.HostsFileLines) {
-		h.Lock()
-		h.HostsFileLines = append(h.HostsFileLines[:row], h.HostsFileLines[row+1:]...)
-		h.Unlock()
+	if row < 0 || row >= len(h.HostsFileLines) {
+		return
 	}
+
+	h.removeRows([]int{row})
 }
 
 // RemoveHostFileLinesByIP remove every line that matches a given IP
 func (h *HostsFile) RemoveHostsFileLinesByIP(ip net.IP) {
-	for idx := len(h.HostsFileLines) - 1; idx >= 0; idx-- {
-		if net.IP.Equal(ip, h.HostsFileLines[idx].Address) {
-			h.RemoveHostsFileLineByRow(idx)
-		}
-	}
+	h.applyAllowed(SelectIP(ip), h.RemoveHostsFileLinesBySelector)
 }
 
 // RemoveHostFileLinesByAddress remove every line that matches a given IP as String
@@ -250,35 +297,70 @@ func (h *HostsFile) RemoveHostsFileLinesByAddress(address string) {
 
 // RemoveHostFileLinesByHostname remove every line that matches a given Hostname
 func (h *HostsFile) RemoveHostsFileLinesByHostname(hostname string) {
-	for idx := len(h.HostsFileLines) - 1; idx >= 0; idx-- {
-		if h.HostsFileLines[idx].Type == LineTypeAddress {
-			for _, hn := range h.HostsFileLines[idx].Hostnames {
-				if hn == hostname {
-					h.RemoveHostsFileLineByRow(idx)
-					continue
-				}
-			}
-		}
-	}
+	h.applyAllowed(selectExactHostname(hostname), h.RemoveHostsFileLinesBySelector)
 }
 
 // RemoveHostFileLinesByRegexp remove every line that matches a given regexp
 func (h *HostsFile) RemoveHostsFileLinesByRegexp(pattern string) {
-	reg := regexp.MustCompile(pattern)
+	h.applyAllowed(SelectRegexp(pattern), h.RemoveHostsFileLinesBySelector)
+}
 
-	for idx := len(h.HostsFileLines) - 1; idx >= 0; idx-- {
-		for _, hn := range h.HostsFileLines[idx].Hostnames {
-			if reg.MatchString(hn) {
-				h.RemoveHostsFileLineByRow(idx)
-				continue
-			}
+// RemoveHostsFileLinesBySelector remove every line that matches a given Selector.
+// error is not nil if a hook vetoes the change, no line is removed in that case
+func (h *HostsFile) RemoveHostsFileLinesBySelector(sel Selector) error {
+	return h.removeLines(h.selectRows(sel))
+}
+
+// RemoveHostsFileLinesByDomain remove every line with a hostname equal to, or under, the given domain
+func (h *HostsFile) RemoveHostsFileLinesByDomain(domain string) {
+	h.applyAllowed(SelectDomain(domain), h.RemoveHostsFileLinesBySelector)
+}
+
+// RemoveHostsFileLinesByGlob remove every line with a hostname that matches a given glob pattern
+func (h *HostsFile) RemoveHostsFileLinesByGlob(pattern string) {
+	h.applyAllowed(SelectGlob(pattern), h.RemoveHostsFileLinesBySelector)
+}
+
+// applyAllowed applies the mutation built by apply from sel, for the methods without an error result.
+// rows vetoed by a Policy are left out and the mutation is built again, other vetoes are ignored
+func (h *HostsFile) applyAllowed(sel Selector, apply func(sel Selector) error) {
+	vetoed := make(map[int]bool)
+
+	for {
+		err := apply(func(row int, hfl *HostsFileLine) bool {
+			return !vetoed[row] && sel(row, hfl)
+		})
+
+		var violation *PolicyViolationError
+		if !errors.As(err, &violation) || violation.Row < 0 || vetoed[violation.Row] {
+			return
+		}
+
+		vetoed[violation.Row] = true
+	}
+}
+
+// selectRows returns the rows matching the given Selector and copies of their lines.
+// the Selector is called on a copy of the content
+func (h *HostsFile) selectRows(sel Selector) ([]int, []HostsFileLine) {
+	rows := make([]int, 0)
+	lines := make([]HostsFileLine, 0)
+
+	current := h.snapshot()
+	for idx := range current {
+		if sel(idx, &current[idx]) {
+			rows = append(rows, idx)
+			lines = append(lines, current[idx])
 		}
 	}
+
+	return rows, lines
 }
 
 // LookupByHostname check if the given fqdn exists.
 // if yes, it returns the index of the address and the associated address.
-// error is not nil if something goes wrong
+// error is not nil if something goes wrong.
+// commented lines are matched too, use Resolve to emulate the system resolver
 func (h *HostsFile) LookupByHostname(hostname string) (int, net.IP, error) {
 	for idx, hfl := range h.HostsFileLines {
 		for _, hn := range hfl.Hostnames {
@@ -298,153 +380,47 @@ func (h *HostsFile) AddHostsFileLineRaw(ipRaw, fqdnRaw, comment string) (int, *H
 	// hostname to lowercase
 	hostname := strings.ToLower(fqdnRaw)
 	// parse ip to net.IP
-	ip := net.ParseIP(ipRaw)
+	ip, zone, err := h.parseAddress(ipRaw)
 
 	// get index
 	idx := len(h.HostsFileLines)
 
 	// if we have a valid IP
-	if ip != nil {
+	if err == nil {
 		// create a new hosts line
 		hfl := HostsFileLine{
 			Type:        LineTypeAddress,
 			Address:     ip,
+			Zone:        zone,
 			Hostnames:   []string{hostname},
 			Comment:     comment,
 			IsCommented: false,
 		}
 
 		// append to hosts
-		h.HostsFileLines = append(h.HostsFileLines, hfl)
+		if err := h.insertLines(idx, hfl); err != nil {
+			return -1, nil, err
+		}
 
 		// return created entry
 		return idx, &hfl, nil
 	}
 
 	// return error
-	return -1, nil, ErrCannotParseIPAddress(ipRaw)
+	return -1, nil, err
 }
 
 // AddHostsFileLine add the given ip/fqdn/comment pair, cleanup is done for previous entry.
 // it returns the index of the edited (created) line and a pointer to the hostsfileline object.
 // error is not nil if something goes wrong
 func (h *HostsFile) AddHostsFileLine(ipRaw, fqdnRaw, comment string) (int, *HostsFileLine, error) {
-	// hostname to lowercase
-	hostname := strings.ToLower(fqdnRaw)
-	// parse ip to net.IP
-	ip := net.ParseIP(ipRaw)
-
-	// if we have a valid IP
-	if ip != nil {
-		// check if we alredy have the fqdn
-		if idx, addr, err := h.LookupByHostname(hostname); err == nil {
-			// if actual ip is the same as the given one, we are done
-			if net.IP.Equal(addr, ip) {
-				// handle comment
-				if comment != "" {
-					// just replace the current comment with the new one
-					h.HostsFileLines[idx].Comment = comment
-				}
-				return idx, &h.HostsFileLines[idx], nil
-			}
-
-			// if address is different, we need to remove the hostname from the previous entry
-			for hostIdx, hn := range h.HostsFileLines[idx].Hostnames {
-				// if hostnames matches
-				if hn == hostname {
-					// remove hostname if there's at least one another hostname
-					if len(h.HostsFileLines[idx].Hostnames) > 1 {
-						h.Lock()
-						h.HostsFileLines[idx].Hostnames = append(h.HostsFileLines[idx].Hostnames[:hostIdx], h.HostsFileLines[idx].Hostnames[hostIdx+1:]...)
-						h.Unlock()
-					} else {
-						// remove the whole line
-						h.RemoveHostsFileLineByRow(idx)
-					}
-				}
-			}
-		}
-
-		// index saves the last matching index for the next for loop
-		// in this way, if we find a matching line (same IP)
-		// but needs to create a new line (hostname limit exceeded)
-		// it would be nice to place the new line next to the existing one
-		index := -1
-
-		// we don't have the fqdn
-		// if we already have the address, just add the hostname to that line
-		for idx, hfl := range h.HostsFileLines {
-			if net.IP.Equal(hfl.Address, ip) {
-				// if we already have 6 hostnames, just continue
-				// we'll either find another matching line
-				// or we'll end up creating a new line
-				if len(h.HostsFileLines[idx].Hostnames) >= 6 {
-					// save index
-					index = idx
-					continue
-				}
-
-				h.Lock()
-				h.HostsFileLines[idx].Hostnames = append(h.HostsFileLines[idx].Hostnames, hostname)
-				h.Unlock()
-
-				// handle comment
-				if comment != "" {
-					// just replace the current comment with the new one
-					h.HostsFileLines[idx].Comment = comment
-				}
-
-				// return edited entry
-				return idx, &h.HostsFileLines[idx], nil
-			}
-		}
-
-		var idx int
-		// we found a matching line
-		if index != -1 {
-			// so set the index next to the matching line
-			idx = index + 1
-		} else {
-			idx = len(h.HostsFileLines)
-		}
-
-		// at this point we need to create new host line
-		hfl := HostsFileLine{
-			Type:        LineTypeAddress,
-			Address:     ip,
-			Hostnames:   []string{hostname},
-			Raw:         "",
-			Comment:     comment,
-			IsCommented: false,
-		}
-
-		// generate raw version of the line
-		hfl.Raw = lineFormatter(hfl)
-
-		// if we found a matching line (index != -1)
-		// append the new line after the matched one
-		if index != -1 {
-			h.HostsFileLines = slices.Insert(h.HostsFileLines, idx, hfl)
-		} else {
-			// else append to hosts
-			h.HostsFileLines = append(h.HostsFileLines, hfl)
-		}
-
-		// return created entry
-		return idx, &h.HostsFileLines[idx], nil
-	}
-
-	// return error
-	return -1, nil, ErrCannotParseIPAddress(ipRaw)
+	return h.AddHostsFileLineWithOptions(ipRaw, fqdnRaw, comment, AddOptions{})
 }
 
 // AddCommentFileLine adds a new line of type comment with the given comment.
 // it returns the index of the edited (created) line and a pointer to the hostsfileline object.
 // error is not nil if something goes wrong
 func (h *HostsFile) AddCommentFileLine(comment string) (int, *HostsFileLine, error) {
-	h.Lock()
-	defer h.Unlock()
-
 	idx := len(h.HostsFileLines)
 
 	hfl := HostsFileLine{
@@ -458,7 +434,10 @@ func (h *HostsFile) AddCommentFileLine(comment string) (int, *HostsFileLine, err
 
 	hfl.Raw = lineFormatter(hfl)
 
-	h.HostsFileLines = append(h.HostsFileLines, hfl)
+	if err := h.insertLines(idx, hfl); err != nil {
+		return -1, nil, err
+	}
+
 	return idx, &h.HostsFileLines[idx], nil
 }
 
@@ -466,9 +445,6 @@ func (h *HostsFile) AddCommentFileLine(comment string) (int, *HostsFileLine, err
 // it returns the index of the edited (created) line and a pointer to the hostsfileline object.
 // error is not nil if something goes wrong
 func (h *HostsFile) AddEmptyFileLine() (int, *HostsFileLine, error) {
-	h.Lock()
-	defer h.Unlock()
-
 	idx := len(h.HostsFileLines)
 
 	hfl := HostsFileLine{
@@ -480,22 +456,19 @@ func (h *HostsFile) AddEmptyFileLine() (int, *HostsFileLine, error) {
 		IsCommented: false,
 	}
 
-	h.HostsFileLines = append(h.HostsFileLines, hfl)
+	if err := h.insertLines(idx, hfl); err != nil {
+		return -1, nil, err
+	}
+
 	return idx, &h.HostsFileLines[idx], nil
 }
 
 // CommentHostsFileLineByRow set the IsCommented bit for the given row to true
 func (h *HostsFile) CommentHostsFileLineByRow(row int) error {
-	h.Lock()
-	defer h.Unlock()
-
 	if len(h.HostsFileLines) > row {
 		if h.HostsFileLines[row].Type == LineTypeAddress {
 			if !h.HostsFileLines[row].IsCommented {
-				h.HostsFileLines[row].IsCommented = true
-
-				h.HostsFileLines[row].Raw = h.RenderHostsFileLine(row)
-				return nil
+				return h.setCommented([]int{row}, h.linesAt([]int{row}), true)
 			}
 
 			return ErrAlredyCommentedLine
@@ -509,18 +482,7 @@ func (h *HostsFile) CommentHostsFileLineByRow(row int) error {
 
 // CommentHostsFileLinesByIP set IsCommented to true on every line that matches a given net.IP
 func (h *HostsFile) CommentHostsFileLinesByIP(ip net.IP) {
-	h.Lock()
-	defer h.Unlock()
-
-	for idx := range h.HostsFileLines {
-		if net.IP.Equal(ip, h.HostsFileLines[idx].Address) {
-			if !h.HostsFileLines[idx].IsCommented {
-				h.HostsFileLines[idx].IsCommented = true
-
-				h.HostsFileLines[idx].Raw = h.RenderHostsFileLine(idx)
-			}
-		}
-	}
+	h.applyAllowed(SelectIP(ip), h.CommentHostsFileLinesBySelector)
 }
 
 // CommentHostsFileLinesByAddress set IsCommented to true on every line that matches a given net.IP
@@ -532,55 +494,38 @@ func (h *HostsFile) CommentHostsFileLinesByAddress(address string) {
 
 // CommentHostsFileLinesByHostname set IsCommented to true on every line that matches a given Hostname
 func (h *HostsFile) CommentHostsFileLinesByHostname(hostname string) {
-	h.Lock()
-	defer h.Unlock()
-
-	for idx := range h.HostsFileLines {
-		for _, hn := range h.HostsFileLines[idx].Hostnames {
-			if hn == hostname {
-				if !h.HostsFileLines[idx].IsCommented {
-					h.HostsFileLines[idx].IsCommented = true
-
-					h.HostsFileLines[idx].Raw = h.RenderHostsFileLine(idx)
-				}
-			}
-		}
-	}
+	h.applyAllowed(selectExactHostname(hostname), h.CommentHostsFileLinesBySelector)
 }
 
 // CommentHostsFileLinesByRegexp set IsCommented to true on every line that matches a given regexp
 func (h *HostsFile) CommentHostsFileLinesByRegexp(pattern string) {
-	h.Lock()
-	defer h.Unlock()
+	h.applyAllowed(SelectRegexp(pattern), h.CommentHostsFileLinesBySelector)
+}
 
-	reg := regexp.MustCompile(pattern)
+// CommentHostsFileLinesBySelector set IsCommented to true on every address line that matches a given Selector.
+// error is not nil if a hook vetoes the change, no line is commented in that case
+func (h *HostsFile) CommentHostsFileLinesBySelector(sel Selector) error {
+	rows, old := h.selectAddressRows(sel, false)
 
-	for idx := range h.HostsFileLines {
-		for _, hn := range h.HostsFileLines[idx].Hostnames {
-			if reg.MatchString(hn) {
-				if !h.HostsFileLines[idx].IsCommented {
-					h.HostsFileLines[idx].IsCommented = true
+	return h.setCommented(rows, old, true)
+}
 
-					h.HostsFileLines[idx].Raw = h.RenderHostsFileLine(idx)
-					continue
-				}
-			}
-		}
-	}
+// CommentHostsFileLinesByDomain set IsCommented to true on every line with a hostname equal to, or under, the given domain
+func (h *HostsFile) CommentHostsFileLinesByDomain(domain string) {
+	h.applyAllowed(SelectDomain(domain), h.CommentHostsFileLinesBySelector)
+}
+
+// CommentHostsFileLinesByGlob set IsCommented to true on every line with a hostname that matches a given glob pattern
+func (h *HostsFile) CommentHostsFileLinesByGlob(pattern string) {
+	h.applyAllowed(SelectGlob(pattern), h.CommentHostsFileLinesBySelector)
 }
 
 // UncommentHostsFileLineByRow set the IsCommented bit for the given row to false
 func (h *HostsFile) UncommentHostsFileLineByRow(row int) error {
-	h.Lock()
-	defer h.Unlock()
-
 	if len(h.HostsFileLines) > row {
 		if h.HostsFileLines[row].Type == LineTypeAddress {
 			if h.HostsFileLines[row].IsCommented {
-				h.HostsFileLines[row].IsCommented = false
-
-				h.HostsFileLines[row].Raw = h.RenderHostsFileLine(row)
-				return nil
+				return h.setCommented([]int{row}, h.linesAt([]int{row}), false)
 			}
 
 			return ErrAlredyUncommentedLine
@@ -594,18 +539,7 @@ func (h *HostsFile) UncommentHostsFileLineByRow(row int) error {
 
 // UncommentHostsFileLinesByIP set IsCommented to false for every line that matches a given net.IP
 func (h *HostsFile) UncommentHostsFileLinesByIP(ip net.IP) {
-	h.Lock()
-	defer h.Unlock()
-
-	for idx := range h.HostsFileLines {
-		if net.IP.Equal(ip, h.HostsFileLines[idx].Address) {
-			if h.HostsFileLines[idx].IsCommented {
-				h.HostsFileLines[idx].IsCommented = false
-
-				h.HostsFileLines[idx].Raw = h.RenderHostsFileLine(idx)
-			}
-		}
-	}
+	h.applyAllowed(SelectIP(ip), h.UncommentHostsFileLinesBySelector)
 }
 
 // UncommentHostsFileLinesByAddress set IsCommented to false for every line that matches a given net.IP as String
@@ -616,39 +550,36 @@ func (h *HostsFile) UncommentHostsFileLinesByAddress(address string) {
 
 // UncommentHostsFileLinesByHostname set IsCommented to false for every line that matches a given Hostname
 func (h *HostsFile) UncommentHostsFileLinesByHostname(hostname string) {
-	h.Lock()
-	defer h.Unlock()
-
-	for idx := range h.HostsFileLines {
-		for _, hn := range h.HostsFileLines[idx].Hostnames {
-			if hn == hostname {
-				if h.HostsFileLines[idx].IsCommented {
-					h.HostsFileLines[idx].IsCommented = false
-
-					h.HostsFileLines[idx].Raw = h.RenderHostsFileLine(idx)
-				}
-			}
-		}
-	}
+	h.applyAllowed(selectExactHostname(hostname), h.UncommentHostsFileLinesBySelector)
 }
 
 // UncommentHostsFileLinesByRegexp set IsCommented to false for every line that matches a given regexp
 func (h *HostsFile) UncommentHostsFileLinesByRegexp(pattern string) {
-	h.Lock()
-	defer h.Unlock()
+	h.applyAllowed(SelectRegexp(pattern), h.UncommentHostsFileLinesBySelector)
+}
 
-	reg := regexp.MustCompile(pattern)
+// UncommentHostsFileLinesBySelector set IsCommented to false for every address line that matches a given Selector.
+// error is not nil if a hook vetoes the change, no line is uncommented in that case
+func (h *HostsFile) UncommentHostsFileLinesBySelector(sel Selector) error {
+	rows, old := h.selectAddressRows(sel, true)
 
-	for idx := range h.HostsFileLines {
-		for _, hn := range h.HostsFileLines[idx].Hostnames {
-			if reg.MatchString(hn) {
-				if h.HostsFileLines[idx].IsCommented {
-					h.HostsFileLines[idx].IsCommented = false
+	return h.setCommented(rows, old, false)
+}
 
-					h.HostsFileLines[idx].Raw = h.RenderHostsFileLine(idx)
-					continue
-				}
-			}
-		}
-	}
+// UncommentHostsFileLinesByDomain set IsCommented to false for every line with a hostname equal to, or under, the given domain
+func (h *HostsFile) UncommentHostsFileLinesByDomain(domain string) {
+	h.applyAllowed(SelectDomain(domain), h.UncommentHostsFileLinesBySelector)
+}
+
+// UncommentHostsFileLinesByGlob set IsCommented to false for every line with a hostname that matches a given glob pattern
+func (h *HostsFile) UncommentHostsFileLinesByGlob(pattern string) {
+	h.applyAllowed(SelectGlob(pattern), h.UncommentHostsFileLinesBySelector)
+}
+
+// selectAddressRows returns the address rows matching the given Selector, with the given commented state,
+// and copies of their lines
+func (h *HostsFile) selectAddressRows(sel Selector, commented bool) ([]int, []HostsFileLine) {
+	return h.selectRows(func(row int, hfl *HostsFileLine) bool {
+		return hfl.Type == LineTypeAddress && hfl.IsCommented == commented && sel(row, hfl)
+	})
 }
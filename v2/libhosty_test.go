@@ -96,6 +96,94 @@ func TestGetHostsFileLinesByRegexp(t *testing.T) {
 	//TODO(areYouLazy): Test missing
 }
 
+// newTestHostsFile returns a HostsFile holding the given address, hostname and comment entries,
+// added in order with AddHostsFileLine
+func newTestHostsFile(t *testing.T, entries ...[3]string) *HostsFile {
+	t.Helper()
+
+	h := New()
+
+	for _, e := range entries {
+		if _, _, err := h.AddHostsFileLine(e[0], e[1], e[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return h
+}
+
+// selectorTestEntries are the entries used by the selector tests
+var selectorTestEntries = [][3]string{
+	{"10.0.0.1", "example.com", ""},
+	{"10.0.0.2", "www.example.com", ""},
+	{"10.0.0.3", "badexample.com", ""},
+	{"10.0.0.4", "api.staging.corp", ""},
+	{"10.0.0.5", "staging.corp", ""},
+}
+
+func TestGetHostsFileLinesByDomain(t *testing.T) {
+	h := newTestHostsFile(t, selectorTestEntries...)
+
+	hfl := h.GetHostsFileLinesByDomain("example.com")
+	if len(hfl) != 2 {
+		t.Fatalf("wants %d lines got %d", 2, len(hfl))
+	}
+
+	for _, l := range hfl {
+		if l.Hostnames[0] == "badexample.com" {
+			t.Fatal("badexample.com should not match example.com")
+		}
+	}
+}
+
+func TestGetHostsFileLinesByGlob(t *testing.T) {
+	h := newTestHostsFile(t, selectorTestEntries...)
+
+	hfl := h.GetHostsFileLinesByGlob("*.staging.corp")
+	if len(hfl) != 1 {
+		t.Fatalf("wants %d lines got %d", 1, len(hfl))
+	}
+
+	if hfl[0].Hostnames[0] != "api.staging.corp" {
+		t.Fatalf("wants %q got %q", "api.staging.corp", hfl[0].Hostnames[0])
+	}
+}
+
+func TestRemoveHostsFileLinesByDomain(t *testing.T) {
+	h := newTestHostsFile(t, selectorTestEntries...)
+
+	h.RemoveHostsFileLinesByDomain("example.com")
+
+	if len(h.HostsFileLines) != 3 {
+		t.Fatalf("wants %d lines got %d", 3, len(h.HostsFileLines))
+	}
+
+	if len(h.GetHostsFileLinesByHostname("badexample.com")) != 1 {
+		t.Fatal("badexample.com should not be removed")
+	}
+}
+
+func TestCommentHostsFileLinesByGlob(t *testing.T) {
+	h := newTestHostsFile(t, selectorTestEntries...)
+
+	h.CommentHostsFileLinesByGlob("*.corp")
+
+	for _, hfl := range h.HostsFileLines {
+		wants := hfl.Hostnames[0] == "api.staging.corp" || hfl.Hostnames[0] == "staging.corp"
+		if hfl.IsCommented != wants {
+			t.Fatalf("%s: wants IsCommented=%t got %t", hfl.Hostnames[0], wants, hfl.IsCommented)
+		}
+	}
+
+	h.UncommentHostsFileLinesByDomain("staging.corp")
+
+	for _, hfl := range h.HostsFileLines {
+		if hfl.IsCommented {
+			t.Fatalf("%s: should be uncommented", hfl.Hostnames[0])
+		}
+	}
+}
+
 func TestRemoveHostsFileLineByRow(t *testing.T) {
 	//TODO(areYouLazy): Test missing
 }
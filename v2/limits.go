@@ -0,0 +1,159 @@
+package libhosty
+
+import "net"
+
+// DefaultMaxHostnamesPerLine defines the default maximum number of hostnames on a single address line
+const DefaultMaxHostnamesPerLine = 6
+
+// lineLimits holds the limits applied when packing hostnames into address lines
+type lineLimits struct {
+	// maximum number of hostnames per line
+	maxHostnames int
+
+	// maximum length of a rendered line, 0 means unlimited
+	maxLength int
+}
+
+// defaultLineLimits are used when no other limit is configured
+var defaultLineLimits = lineLimits{
+	maxHostnames: DefaultMaxHostnamesPerLine,
+	maxLength:    0,
+}
+
+// limits returns the line limits configured on the HostsFile,
+// falling back to the dialect ones
+func (h *HostsFile) limits() lineLimits {
+	l := defaultLineLimits
+	d := h.dialect()
+
+	if d.MaxHostnamesPerLine > 0 {
+		l.maxHostnames = d.MaxHostnamesPerLine
+	}
+
+	if d.MaxLineLength > 0 {
+		l.maxLength = d.MaxLineLength
+	}
+
+	if h.MaxHostnamesPerLine > 0 {
+		l.maxHostnames = h.MaxHostnamesPerLine
+	}
+
+	if h.MaxLineLength > 0 {
+		l.maxLength = h.MaxLineLength
+	}
+
+	return l
+}
+
+// fits reports whether the given line is within limits
+func (l lineLimits) fits(hfl HostsFileLine) bool {
+	if len(hfl.Hostnames) > l.maxHostnames {
+		return false
+	}
+
+	if l.maxLength > 0 && len(lineFormatter(hfl)) > l.maxLength {
+		return false
+	}
+
+	return true
+}
+
+// canAppend reports whether hostname can be added to the given line without exceeding limits
+func (l lineLimits) canAppend(hfl HostsFileLine, hostname string) bool {
+	hfl.Hostnames = append(hfl.Hostnames[:len(hfl.Hostnames):len(hfl.Hostnames)], hostname)
+
+	return l.fits(hfl)
+}
+
+// splitHostsFileLine splits an address line exceeding limits into multiple lines,
+// every resulting line holds at least one hostname.
+// lines within limits are returned untouched
+func splitHostsFileLine(hfl HostsFileLine, l lineLimits) []HostsFileLine {
+	if hfl.Type != LineTypeAddress || l.fits(hfl) {
+		return []HostsFileLine{hfl}
+	}
+
+	res := make([]HostsFileLine, 0)
+
+	// get a new line (copy of hfl, except for Hostnames and Raw)
+	newLine := func() HostsFileLine {
+		return HostsFileLine{
+			Type:        hfl.Type,
+			Address:     hfl.Address,
+			Zone:        hfl.Zone,
+			Hostnames:   make([]string, 0),
+			Comment:     hfl.Comment,
+			Annotations: cloneAnnotations(hfl.Annotations),
+			IsCommented: hfl.IsCommented,
+		}
+	}
+
+	cur := newLine()
+	for _, hostname := range hfl.Hostnames {
+		if len(cur.Hostnames) > 0 && !l.canAppend(cur, hostname) {
+			cur.Raw = lineFormatter(cur)
+			res = append(res, cur)
+			cur = newLine()
+		}
+
+		cur.Hostnames = append(cur.Hostnames, hostname)
+	}
+
+	cur.Raw = lineFormatter(cur)
+	res = append(res, cur)
+
+	return res
+}
+
+// Repack re-packs address lines according to MaxHostnamesPerLine and MaxLineLength.
+// adjacent lines with the same address, comment and commented state are merged,
+// then lines exceeding limits are split.
+// error is not nil if a hook vetoes the change
+func (h *HostsFile) Repack() error {
+	l := h.limits()
+	current := h.snapshot()
+	res := make([]HostsFileLine, 0, len(current))
+
+	for _, hfl := range current {
+		if last := len(res) - 1; last >= 0 && hfl.Type == LineTypeAddress && canMerge(res[last], hfl) {
+			res[last].Hostnames = append(res[last].Hostnames, hfl.Hostnames...)
+			res[last].Raw = ""
+			continue
+		}
+
+		if hfl.Type == LineTypeAddress {
+			// copy hostnames to avoid sharing the backing array with the original line
+			hfl.Hostnames = append([]string{}, hfl.Hostnames...)
+		}
+
+		res = append(res, hfl)
+	}
+
+	packed := make([]HostsFileLine, 0, len(res))
+	for _, hfl := range res {
+		split := splitHostsFileLine(hfl, l)
+
+		// refresh raw for merged lines
+		if len(split) == 1 && split[0].Type == LineTypeAddress && split[0].Raw == "" {
+			split[0].Raw = lineFormatter(split[0])
+		}
+
+		packed = append(packed, split...)
+	}
+
+	if equalHostsFileLinesSlice(packed, current) {
+		return nil
+	}
+
+	return h.replaceLines(current, packed)
+}
+
+// canMerge reports whether b can be merged into a
+func canMerge(a, b HostsFileLine) bool {
+	return a.Type == LineTypeAddress &&
+		b.Type == LineTypeAddress &&
+		net.IP.Equal(a.Address, b.Address) &&
+		a.Zone == b.Zone &&
+		a.IsCommented == b.IsCommented &&
+		a.Comment == b.Comment
+}
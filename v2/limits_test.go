@@ -0,0 +1,95 @@
+package libhosty
+
+import (
+	"testing"
+)
+
+func TestSplitHostsFileLine(t *testing.T) {
+	hfl := HostsFileLine{
+		Type:      LineTypeAddress,
+		Address:   []byte{10, 0, 0, 1},
+		Hostnames: []string{"a", "b", "c", "d", "e"},
+	}
+
+	lines := splitHostsFileLine(hfl, lineLimits{maxHostnames: 2})
+	if len(lines) != 3 {
+		t.Fatalf("wants %d lines got %d", 3, len(lines))
+	}
+
+	if len(lines[2].Hostnames) != 1 || lines[2].Hostnames[0] != "e" {
+		t.Fatalf("unexpected last line: %v", lines[2])
+	}
+
+	// "10.0.0.1         a b" is 20 chars long
+	lines = splitHostsFileLine(hfl, lineLimits{maxHostnames: 6, maxLength: 20})
+	if len(lines) != 3 {
+		t.Fatalf("wants %d lines got %d", 3, len(lines))
+	}
+
+	for _, l := range lines {
+		if len(l.Raw) > 20 {
+			t.Fatalf("line exceeds max length: %q", l.Raw)
+		}
+	}
+}
+
+func TestParserSplitsLongLines(t *testing.T) {
+	hfl, err := ParseHostsFileFromString("10.0.0.1 a b c d e f g # comment")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hfl) != 2 {
+		t.Fatalf("wants %d lines got %d", 2, len(hfl))
+	}
+
+	if len(hfl[0].Hostnames) != DefaultMaxHostnamesPerLine || hfl[1].Hostnames[0] != "g" {
+		t.Fatalf("unexpected split: %v", hfl)
+	}
+
+	if hfl[1].Comment != "comment" {
+		t.Fatalf("wants %q got %q", "comment", hfl[1].Comment)
+	}
+}
+
+func TestAddHostsFileLineRespectsLimits(t *testing.T) {
+	h := &HostsFile{MaxHostnamesPerLine: 2}
+
+	for _, hn := range []string{"a.local", "b.local", "c.local"} {
+		if _, _, err := h.AddHostsFileLine("10.0.0.1", hn, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(h.HostsFileLines) != 2 {
+		t.Fatalf("wants %d lines got %d", 2, len(h.HostsFileLines))
+	}
+}
+
+func TestRepack(t *testing.T) {
+	h, err := InitFromString("10.0.0.1 a b\n10.0.0.1 c\n# comment\n10.0.0.1 d e f")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.MaxHostnamesPerLine = 4
+	h.Repack()
+
+	wants := []string{"10.0.0.1         a b c", "# comment", "10.0.0.1         d e f"}
+	if len(h.HostsFileLines) != len(wants) {
+		t.Fatalf("wants %d lines got %d", len(wants), len(h.HostsFileLines))
+	}
+
+	for idx, w := range wants {
+		if got := h.RenderHostsFileLine(idx); got != w {
+			t.Fatalf("wants %q got %q", w, got)
+		}
+	}
+
+	h.MaxHostnamesPerLine = 2
+	h.Repack()
+
+	if len(h.HostsFileLines) != 5 {
+		t.Fatalf("wants %d lines got %d", 5, len(h.HostsFileLines))
+	}
+}
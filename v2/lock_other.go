@@ -0,0 +1,10 @@
+//go:build !unix
+
+package libhosty
+
+import "context"
+
+// lockFile is a no-op on platforms without flock(2)
+func lockFile(ctx context.Context, path string, exclusive bool) (func(), error) {
+	return func() {}, ctx.Err()
+}
@@ -0,0 +1,61 @@
+//go:build unix
+
+package libhosty
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// lockRetryInterval defines how often a busy lock is retried
+const lockRetryInterval = 10 * time.Millisecond
+
+// lockFile acquires a flock(2) advisory lock on the given path, retrying until ctx is done.
+// the lock is held on the directory of the file, symlinks followed, since writes rename
+// a new file over it and a lock on the file itself would not be seen by the next writer.
+// the returned function releases the lock
+func lockFile(ctx context.Context, path string, exclusive bool) (func(), error) {
+	target, err := resolveWriteTarget(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filepath.Dir(target))
+	if err != nil {
+		return nil, err
+	}
+
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	for {
+		err = syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+		if err == nil {
+			return func() {
+				syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+				f.Close()
+			}, nil
+		}
+
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			f.Close()
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, ErrLockTimeout
+			}
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
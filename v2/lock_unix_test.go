@@ -0,0 +1,47 @@
+//go:build unix
+
+package libhosty
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLockTimeout(t *testing.T) {
+	path := writeTestHostsFile(t, "127.0.0.1 localhost\n")
+
+	unlock, err := lockFile(context.Background(), path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(context.Background(), path, WithLockTimeout(50*time.Millisecond)); err != ErrLockTimeout {
+		t.Fatalf("wants %v got %v", ErrLockTimeout, err)
+	}
+
+	unlock()
+
+	if _, err := Open(context.Background(), path, WithLockTimeout(50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockSurvivesReplace(t *testing.T) {
+	path := writeTestHostsFile(t, "127.0.0.1 localhost\n")
+
+	unlock, err := lockFile(context.Background(), path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	// the file is renamed over while the lock is held
+	if err := writeFile(path, []byte("127.0.0.1 localhost\n10.0.0.1 db\n"), fileAttrs{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(context.Background(), path, WithLockTimeout(50*time.Millisecond)); err != ErrLockTimeout {
+		t.Fatalf("wants %v got %v", ErrLockTimeout, err)
+	}
+}
@@ -0,0 +1,142 @@
+package libhosty
+
+import (
+	"net"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Selector reports whether the HostsFileLine found at the given row should be selected.
+// Selectors are used by the *BySelector family of methods
+type Selector func(row int, hfl *HostsFileLine) bool
+
+// SelectHostname returns a Selector that matches lines containing the given hostname
+func SelectHostname(hostname string) Selector {
+	hostname = normalizeHostname(hostname)
+
+	return selectAnyHostname(func(hn string) bool {
+		return normalizeHostname(hn) == hostname
+	})
+}
+
+// SelectIP returns a Selector that matches lines with the given net.IP
+func SelectIP(ip net.IP) Selector {
+	return func(_ int, hfl *HostsFileLine) bool {
+		return ip != nil && net.IP.Equal(ip, hfl.Address)
+	}
+}
+
+// SelectAddress returns a Selector that matches lines with the given IP as String
+func SelectAddress(address string) Selector {
+	return SelectIP(net.ParseIP(address))
+}
+
+// SelectRegexp returns a Selector that matches lines with at least one hostname matching the given regexp
+func SelectRegexp(pattern string) Selector {
+	reg := regexp.MustCompile(pattern)
+
+	return selectAnyHostname(reg.MatchString)
+}
+
+// SelectDomain returns a Selector that matches lines with at least one hostname
+// equal to the given domain or being a subdomain of it.
+// matching is label-aware, so example.com does not match badexample.com
+func SelectDomain(domain string) Selector {
+	domain = normalizeHostname(domain)
+
+	return selectAnyHostname(func(hn string) bool {
+		return matchDomain(hn, domain)
+	})
+}
+
+// SelectGlob returns a Selector that matches lines with at least one hostname
+// matching the given glob pattern (see matchGlob for pattern syntax)
+func SelectGlob(pattern string) Selector {
+	return selectAnyHostname(func(hn string) bool {
+		return matchGlob(hn, pattern)
+	})
+}
+
+// SelectRow returns a Selector that matches the line at the given row
+func SelectRow(row int) Selector {
+	return func(idx int, _ *HostsFileLine) bool {
+		return idx == row
+	}
+}
+
+// selectExactHostname returns a Selector that matches lines containing exactly the given hostname
+func selectExactHostname(hostname string) Selector {
+	return selectAnyHostname(func(hn string) bool {
+		return hn == hostname
+	})
+}
+
+// selectAnyHostname returns a Selector that matches lines where at least one hostname satisfies match
+func selectAnyHostname(match func(hostname string) bool) Selector {
+	return func(_ int, hfl *HostsFileLine) bool {
+		for _, hn := range hfl.Hostnames {
+			if match(hn) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// normalizeHostname lower cases the hostname and strips the trailing dot of fully qualified names
+func normalizeHostname(hostname string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(hostname)), ".")
+}
+
+// matchDomain reports whether hostname is domain or one of its subdomains.
+// domain must be already normalized
+func matchDomain(hostname, domain string) bool {
+	if domain == "" {
+		return false
+	}
+
+	hostname = normalizeHostname(hostname)
+
+	return hostname == domain || strings.HasSuffix(hostname, "."+domain)
+}
+
+// matchGlob reports whether hostname matches the given glob pattern.
+// Patterns are matched label by label, with path.Match syntax inside each label,
+// so a wildcard never crosses a dot: web-*.corp matches web-01.corp but not web.01.corp.
+// As a special case, a leading "*" label matches one or more labels,
+// so *.staging.corp matches every name under staging.corp, but not staging.corp itself
+func matchGlob(hostname, pattern string) bool {
+	hostLabels := strings.Split(normalizeHostname(hostname), ".")
+	patternLabels := strings.Split(normalizeHostname(pattern), ".")
+
+	if patternLabels[0] == "*" {
+		rest := patternLabels[1:]
+
+		// we need at least one label for the wildcard
+		if len(hostLabels) <= len(rest) {
+			return false
+		}
+
+		return matchLabels(hostLabels[len(hostLabels)-len(rest):], rest)
+	}
+
+	if len(hostLabels) != len(patternLabels) {
+		return false
+	}
+
+	return matchLabels(hostLabels, patternLabels)
+}
+
+// matchLabels matches every label against the pattern at the same position
+func matchLabels(labels, patterns []string) bool {
+	for idx := range patterns {
+		ok, err := path.Match(patterns[idx], labels[idx])
+		if err != nil || !ok {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,50 @@
+package libhosty
+
+import "testing"
+
+func TestMatchDomain(t *testing.T) {
+	cases := []struct {
+		hostname string
+		domain   string
+		want     bool
+	}{
+		{"example.com", "example.com", true},
+		{"www.example.com", "example.com", true},
+		{"a.b.example.com.", "example.com", true},
+		{"WWW.Example.COM", "example.com", true},
+		{"badexample.com", "example.com", false},
+		{"example.com.evil", "example.com", false},
+		{"example.com", "", false},
+	}
+
+	for _, c := range cases {
+		if got := matchDomain(c.hostname, c.domain); got != c.want {
+			t.Fatalf("matchDomain(%q, %q): wants %t got %t", c.hostname, c.domain, c.want, got)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		hostname string
+		pattern  string
+		want     bool
+	}{
+		{"api.svc.local", "*.svc.local", true},
+		{"a.b.svc.local", "*.svc.local", true},
+		{"svc.local", "*.svc.local", false},
+		{"api.badsvc.local", "*.svc.local", false},
+		{"web-01.corp", "web-*.corp", true},
+		{"web.01.corp", "web-*.corp", false},
+		{"web-01.eu.corp", "web-*.*.corp", true},
+		{"db1.corp", "db?.corp", true},
+		{"db10.corp", "db?.corp", false},
+		{"API.SVC.local", "*.svc.LOCAL", true},
+	}
+
+	for _, c := range cases {
+		if got := matchGlob(c.hostname, c.pattern); got != c.want {
+			t.Fatalf("matchGlob(%q, %q): wants %t got %t", c.hostname, c.pattern, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package libhosty
+
+import (
+	"context"
+	"net"
+
+	"golang.org/x/exp/slices"
+)
+
+// MergeConflict holds a region changed both in memory and on disk in different ways.
+// the in-memory lines are kept in the HostsFile
+type MergeConflict struct {
+	//Row is the index of the first in-memory line of the region in the merged HostsFile
+	Row int
+
+	//Base are the lines of the region as they were loaded
+	Base []HostsFileLine
+
+	//Ours are the lines of the region as they are in memory
+	Ours []HostsFileLine
+
+	//Theirs are the lines of the region as they are on disk
+	Theirs []HostsFileLine
+}
+
+// Reload re-parses the hosts file from Path and merges it with the in-memory edits.
+// it performs a three-way merge between the lines as they were loaded (or last written),
+// the in-memory lines and the lines on disk: changes made on a single side are applied,
+// regions changed on both sides in different ways are returned as conflicts
+// and keep the in-memory lines.
+// the merge is applied as an OpReplace mutation.
+// error is not nil if something goes wrong
+func (h *HostsFile) Reload() ([]MergeConflict, error) {
+	return h.ReloadContext(context.Background())
+}
+
+// ReloadContext is Reload with a context, parsing and lock waits stop when ctx is done.
+// error is not nil if something goes wrong
+func (h *HostsFile) ReloadContext(ctx context.Context) ([]MergeConflict, error) {
+	if h.Path == "" {
+		return nil, ErrPathNotConfigured
+	}
+
+	unlock, err := h.lockFile(ctx, h.Path, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	byteData, err := h.filesystem().ReadFile(h.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	theirs, err := parserContext(ctx, h.content(byteData), h.parserConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	h.Lock()
+	ours := cloneHostsFileLines(h.HostsFileLines)
+	merged, conflicts := merge3(h.base, ours, theirs)
+	h.Unlock()
+
+	if !equalHostsFileLinesSlice(merged, ours) {
+		if err := h.replaceLines(ours, merged); err != nil {
+			return nil, err
+		}
+	}
+
+	h.Lock()
+	h.base = cloneHostsFileLines(theirs)
+	h.loaded = byteData
+	h.Unlock()
+
+	return conflicts, nil
+}
+
+// merge3 merges ours and theirs, both derived from base.
+// regions changed on both sides in different ways keep ours and are returned as conflicts
+func merge3(base, ours, theirs []HostsFileLine) ([]HostsFileLine, []MergeConflict) {
+	toOurs := matchHostsFileLines(base, ours)
+	toTheirs := matchHostsFileLines(base, theirs)
+
+	merged := make([]HostsFileLine, 0, len(ours))
+	conflicts := make([]MergeConflict, 0)
+
+	b, o, t := 0, 0, 0
+
+	for {
+		// next base line kept by both sides, or the end of every file
+		next := b
+		for next < len(base) && (toOurs[next] < 0 || toTheirs[next] < 0) {
+			next++
+		}
+
+		nextOurs, nextTheirs := len(ours), len(theirs)
+		if next < len(base) {
+			nextOurs, nextTheirs = toOurs[next], toTheirs[next]
+		}
+
+		baseChunk := base[b:next]
+		oursChunk := ours[o:nextOurs]
+		theirsChunk := theirs[t:nextTheirs]
+
+		switch {
+		case equalHostsFileLinesSlice(oursChunk, baseChunk):
+			merged = append(merged, theirsChunk...)
+		case equalHostsFileLinesSlice(theirsChunk, baseChunk), equalHostsFileLinesSlice(oursChunk, theirsChunk):
+			merged = append(merged, oursChunk...)
+		default:
+			conflicts = append(conflicts, MergeConflict{
+				Row:    len(merged),
+				Base:   cloneHostsFileLines(baseChunk),
+				Ours:   cloneHostsFileLines(oursChunk),
+				Theirs: cloneHostsFileLines(theirsChunk),
+			})
+			merged = append(merged, oursChunk...)
+		}
+
+		if next == len(base) {
+			return merged, conflicts
+		}
+
+		// the line is the same on every side, keep ours
+		merged = append(merged, ours[nextOurs])
+
+		b, o, t = next+1, nextOurs+1, nextTheirs+1
+	}
+}
+
+// matchHostsFileLines returns, for each line of a, the index of the matching line of b
+// in their longest common subsequence, or -1 if the line is not kept in b.
+// it uses the linear space variant of the Myers diff algorithm, in O((N+M)D) time
+func matchHostsFileLines(a, b []HostsFileLine) []int {
+	res := make([]int, len(a))
+	for i := range res {
+		res[i] = -1
+	}
+
+	// forward and backward furthest reaching paths, shared by every step
+	size := len(a) + len(b) + 4
+	d := &lineDiff{a: a, b: b, res: res, vf: make([]int, size), vb: make([]int, size)}
+	d.compare(0, len(a), 0, len(b))
+
+	return res
+}
+
+// lineDiff holds the state of matchHostsFileLines
+type lineDiff struct {
+	a, b []HostsFileLine
+	res  []int
+
+	// furthest reaching x on each diagonal, forward and backward
+	vf, vb []int
+}
+
+// compare matches the lines of a[a0:a1] and b[b0:b1]
+func (d *lineDiff) compare(a0, a1, b0, b1 int) {
+	// common prefix and suffix are matched directly
+	for a0 < a1 && b0 < b1 && equalHostsFileLines(d.a[a0], d.b[b0]) {
+		d.res[a0] = b0
+		a0++
+		b0++
+	}
+
+	for a1 > a0 && b1 > b0 && equalHostsFileLines(d.a[a1-1], d.b[b1-1]) {
+		a1--
+		b1--
+		d.res[a1] = b1
+	}
+
+	if a0 == a1 || b0 == b1 {
+		return
+	}
+
+	// split on the middle snake of a shortest edit script,
+	// both halves need at least one edit so they are smaller
+	x, y, u, v := d.middleSnake(a0, a1, b0, b1)
+
+	for i := 0; i < u-x; i++ {
+		d.res[x+i] = y + i
+	}
+
+	d.compare(a0, x, b0, y)
+	d.compare(u, a1, v, b1)
+}
+
+// middleSnake returns the start (x, y) and the end (u, v) of the middle snake
+// of a shortest edit script from a[a0:a1] to b[b0:b1]
+func (d *lineDiff) middleSnake(a0, a1, b0, b1 int) (x, y, u, v int) {
+	n, m := a1-a0, b1-b0
+	delta := n - m
+	odd := delta%2 != 0
+
+	limit := (n + m + 1) / 2
+	offset := limit + 1
+
+	vf, vb := d.vf[:2*limit+3], d.vb[:2*limit+3]
+	vf[offset+1], vb[offset+1] = 0, 0
+
+	for step := 0; step <= limit; step++ {
+		// forward paths, from (a0, b0)
+		for k := -step; k <= step; k += 2 {
+			var px int
+			if k == -step || (k != step && vf[offset+k-1] < vf[offset+k+1]) {
+				px = vf[offset+k+1]
+			} else {
+				px = vf[offset+k-1] + 1
+			}
+			py := px - k
+
+			sx, sy := px, py
+			for px < n && py < m && equalHostsFileLines(d.a[a0+px], d.b[b0+py]) {
+				px++
+				py++
+			}
+			vf[offset+k] = px
+
+			// overlaps the backward path on the same diagonal
+			if c := delta - k; odd && c >= -(step-1) && c <= step-1 && px+vb[offset+c] >= n {
+				return a0 + sx, b0 + sy, a0 + px, b0 + py
+			}
+		}
+
+		// backward paths, from (a1, b1), x counted from the end
+		for c := -step; c <= step; c += 2 {
+			var px int
+			if c == -step || (c != step && vb[offset+c-1] < vb[offset+c+1]) {
+				px = vb[offset+c+1]
+			} else {
+				px = vb[offset+c-1] + 1
+			}
+			py := px - c
+
+			sx, sy := px, py
+			for px < n && py < m && equalHostsFileLines(d.a[a1-px-1], d.b[b1-py-1]) {
+				px++
+				py++
+			}
+			vb[offset+c] = px
+
+			// overlaps the forward path on the same diagonal
+			if k := delta - c; !odd && k >= -step && k <= step && px+vf[offset+k] >= n {
+				return a1 - px, b1 - py, a1 - sx, b1 - sy
+			}
+		}
+	}
+
+	// unreachable, a shortest edit script is at most n+m long
+	return a0, b0, a0, b0
+}
+
+// equalHostsFileLinesSlice reports whether two slices hold the same lines, regardless of formatting
+func equalHostsFileLinesSlice(a, b []HostsFileLine) bool {
+	return slices.EqualFunc(a, b, equalHostsFileLines)
+}
+
+// cloneHostsFileLines returns a deep copy of lines
+func cloneHostsFileLines(lines []HostsFileLine) []HostsFileLine {
+	res := make([]HostsFileLine, len(lines))
+
+	for i, hfl := range lines {
+		hfl.Address = append(net.IP(nil), hfl.Address...)
+		hfl.Hostnames = append([]string(nil), hfl.Hostnames...)
+		hfl.Annotations = cloneAnnotations(hfl.Annotations)
+		res[i] = hfl
+	}
+
+	return res
+}
@@ -0,0 +1,194 @@
+package libhosty
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func newMergeTestHostsFile(t *testing.T, content string) (*HostsFile, *MemFS) {
+	t.Helper()
+
+	mfs := NewMemFS()
+	if err := mfs.WriteFile("/etc/hosts", []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := Open(context.Background(), "/etc/hosts", WithFS(mfs), WithDialect(DialectGlibc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h, mfs
+}
+
+func TestReload(t *testing.T) {
+	h, mfs := newMergeTestHostsFile(t, "127.0.0.1 localhost\n10.0.0.1 db\n10.0.0.2 cache\n")
+
+	// our edit
+	if _, _, err := h.AddHostsFileLine("10.0.0.3", "web", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// their edit
+	if err := mfs.WriteFile("/etc/hosts", []byte("127.0.0.1 localhost\n10.0.0.1 db\n10.0.0.9 cache\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conflicts, err := h.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", conflicts)
+	}
+
+	if _, ip, err := h.LookupByHostname("cache"); err != nil || ip.String() != "10.0.0.9" {
+		t.Fatalf("expected their edit, got %v %v", ip, err)
+	}
+
+	if _, ip, err := h.LookupByHostname("web"); err != nil || ip.String() != "10.0.0.3" {
+		t.Fatalf("expected our edit, got %v %v", ip, err)
+	}
+
+	// reloading an unchanged file keeps everything
+	conflicts, err = h.Reload()
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("expected a clean reload, got %v %v", conflicts, err)
+	}
+
+	if _, _, err := h.LookupByHostname("web"); err != nil {
+		t.Fatal("expected our edit to be kept")
+	}
+}
+
+func TestReloadConflict(t *testing.T) {
+	h, mfs := newMergeTestHostsFile(t, "127.0.0.1 localhost\n10.0.0.1 db\n")
+
+	if err := h.RenameHostname("db", "database"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mfs.WriteFile("/etc/hosts", []byte("127.0.0.1 localhost\n10.0.0.3 db\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conflicts, err := h.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conflicts) != 1 {
+		t.Fatalf("expected 1 conflict, got %v", conflicts)
+	}
+
+	c := conflicts[0]
+	if len(c.Base) != 1 || len(c.Ours) != 1 || len(c.Theirs) != 1 {
+		t.Fatalf("unexpected conflict %v", c)
+	}
+
+	if c.Ours[0].Hostnames[0] != "database" || c.Theirs[0].Address.String() != "10.0.0.3" {
+		t.Fatalf("unexpected conflict sides %v %v", c.Ours, c.Theirs)
+	}
+
+	if h.HostsFileLines[c.Row].Hostnames[0] != "database" {
+		t.Fatalf("expected our line to be kept at row %d, got %v", c.Row, h.HostsFileLines[c.Row])
+	}
+}
+
+func TestReloadWithoutPath(t *testing.T) {
+	if _, err := New().Reload(); err != ErrPathNotConfigured {
+		t.Fatalf("expected ErrPathNotConfigured, got %v", err)
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	parse := func(s string) []HostsFileLine {
+		hfl, err := ParseHostsFileFromString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hfl
+	}
+
+	base := parse("# hosts\n10.0.0.1 a\n10.0.0.2 b\n")
+
+	// the same edit on both sides is not a conflict
+	merged, conflicts := merge3(base, parse("# hosts\n10.0.0.1 a\n10.0.0.5 b\n"), parse("# hosts\n10.0.0.1 a\n10.0.0.5 b\n"))
+	if len(conflicts) != 0 || !equalHostsFileLinesSlice(merged, parse("# hosts\n10.0.0.1 a\n10.0.0.5 b\n")) {
+		t.Fatalf("unexpected merge %v %v", merged, conflicts)
+	}
+
+	// removal on one side, edit elsewhere on the other
+	base = parse("# hosts\n10.0.0.1 a\n10.0.0.2 b\n10.0.0.3 c\n")
+	merged, conflicts = merge3(base, parse("# hosts\n10.0.0.1 a\n10.0.0.2 b\n"), parse("# edited\n10.0.0.1 a\n10.0.0.2 b\n10.0.0.3 c\n"))
+	if len(conflicts) != 0 || !equalHostsFileLinesSlice(merged, parse("# edited\n10.0.0.1 a\n10.0.0.2 b\n")) {
+		t.Fatalf("unexpected merge %v %v", merged, conflicts)
+	}
+}
+
+func TestMatchHostsFileLines(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	randomLines := func() []HostsFileLine {
+		lines := make([]HostsFileLine, rnd.Intn(30))
+		for i := range lines {
+			lines[i] = HostsFileLine{Type: LineTypeComment, Comment: string(rune('a' + rnd.Intn(4)))}
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+
+		// reference LCS length
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i].Comment == b[j].Comment {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		matched, last := 0, -1
+		for i, j := range matchHostsFileLines(a, b) {
+			if j < 0 {
+				continue
+			}
+
+			if j <= last || a[i].Comment != b[j].Comment {
+				t.Fatalf("invalid match %d -> %d in %v / %v", i, j, a, b)
+			}
+
+			matched++
+			last = j
+		}
+
+		if matched != lcs[0][0] {
+			t.Fatalf("wants %d matches got %d in %v / %v", lcs[0][0], matched, a, b)
+		}
+	}
+}
+
+func TestMatchHostsFileLinesLarge(t *testing.T) {
+	a := make([]HostsFileLine, 100000)
+	for i := range a {
+		a[i] = HostsFileLine{Type: LineTypeComment, Comment: strconv.Itoa(i)}
+	}
+
+	b := append(cloneHostsFileLines(a[:50000]), HostsFileLine{Type: LineTypeComment, Comment: "new"})
+	b = append(b, cloneHostsFileLines(a[50001:])...)
+
+	res := matchHostsFileLines(a, b)
+	if res[50000] != -1 || res[49999] != 49999 || res[50001] != 50001 {
+		t.Fatalf("unexpected matches around the edit: %v", res[49999:50002])
+	}
+}
@@ -0,0 +1,182 @@
+package libhosty
+
+import (
+	"context"
+	"io/fs"
+	"time"
+)
+
+// PreservationMode define a safe type for how lines are rendered
+type PreservationMode int
+
+const (
+	//PreserveNone renders every line with the library formatting (default)
+	PreserveNone PreservationMode = 0
+
+	//PreserveRaw renders unchanged lines as they were in the original file
+	PreserveRaw PreservationMode = 1
+)
+
+func (pm PreservationMode) String() string {
+	switch pm {
+	case PreserveRaw:
+		return "preserve-raw"
+	default:
+		return "preserve-none"
+	}
+}
+
+// Option configures a HostsFile created with New or Open
+type Option func(h *HostsFile)
+
+// WithDialect sets the dialect used to parse, validate and render the hosts file
+func WithDialect(d Dialect) Option {
+	return func(h *HostsFile) {
+		h.Dialect = d
+	}
+}
+
+// WithStrict makes parsing fail on lines that cannot be recognized,
+// instead of keeping them as LineTypeUnknown
+func WithStrict(strict bool) Option {
+	return func(h *HostsFile) {
+		h.strict = strict
+	}
+}
+
+// WithLockTimeout enables advisory locking of the hosts file while reading and writing it,
+// waiting at most timeout to acquire the lock. 0 disables locking (default).
+// on unix the lock is held on the directory of the hosts file
+// so that it survives the file being replaced on write
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(h *HostsFile) {
+		h.lockTimeout = timeout
+	}
+}
+
+// WithPreservation sets how lines are rendered
+func WithPreservation(mode PreservationMode) Option {
+	return func(h *HostsFile) {
+		h.Preserve = mode
+	}
+}
+
+// WithFS sets the filesystem used to read and write the hosts file
+func WithFS(fsys FS) Option {
+	return func(h *HostsFile) {
+		h.fs = fsys
+	}
+}
+
+// WithIOFS sets a read-only fs.FS as the filesystem used to read the hosts file,
+// writes fail with ErrReadOnlyFS
+func WithIOFS(fsys fs.FS) Option {
+	return WithFS(ReadOnlyFS(fsys))
+}
+
+// WithLineLimits sets the maximum number of hostnames and the maximum length of address lines,
+// 0 means the dialect default
+func WithLineLimits(maxHostnames, maxLength int) Option {
+	return func(h *HostsFile) {
+		h.MaxHostnamesPerLine = maxHostnames
+		h.MaxLineLength = maxLength
+	}
+}
+
+// WithFileMode sets the permissions of hosts files created by writes, 0644 by default.
+// existing files keep their permissions
+func WithFileMode(mode fs.FileMode) Option {
+	return func(h *HostsFile) {
+		h.newFile.perm = mode
+	}
+}
+
+// WithFileOwner sets the owner of hosts files created by writes, the process owner by default.
+// existing files keep their owner
+func WithFileOwner(uid, gid int) Option {
+	return func(h *HostsFile) {
+		h.newFile.uid = uid
+		h.newFile.gid = gid
+		h.newFile.hasOwner = true
+	}
+}
+
+// New returns a new, empty, instance of a hostsfile configured with the given options.
+// Path is not set, use WriteHostsFileTo(path) or set it before WriteHostsFile()
+func New(opts ...Option) *HostsFile {
+	h := &HostsFile{
+		HostsFileLines: make([]HostsFileLine, 0),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Open returns a new instance of a hostsfile loaded from the given path and configured with the given options.
+// parsing and lock waits stop when ctx is done.
+// error is not nil if something goes wrong
+func Open(ctx context.Context, path string, opts ...Option) (*HostsFile, error) {
+	h := New(opts...)
+	h.Path = path
+
+	unlock, err := h.lockFile(ctx, path, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	byteData, err := h.filesystem().ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.load(ctx, byteData); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// load parses data with the HostsFile configuration and replaces its lines
+func (h *HostsFile) load(ctx context.Context, data []byte) error {
+	hfl, err := parserContext(ctx, h.content(data), h.parserConfig())
+	if err != nil {
+		return err
+	}
+
+	h.Lock()
+	h.HostsFileLines = hfl
+	h.base = cloneHostsFileLines(hfl)
+	h.loaded = data
+	h.Unlock()
+
+	return nil
+}
+
+// content returns the lines of data to parse, without the integrity header
+// if the HostsFile maintains it
+func (h *HostsFile) content(data []byte) []byte {
+	if h.integrity {
+		return integrityContent(data)
+	}
+
+	return data
+}
+
+// lockFile acquires an advisory lock on the given path,
+// if locking is enabled and supported by the filesystem.
+// the returned function releases the lock
+func (h *HostsFile) lockFile(ctx context.Context, path string, exclusive bool) (func(), error) {
+	locker, ok := h.filesystem().(fileLocker)
+	if h.lockTimeout <= 0 || !ok {
+		return func() {}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.lockTimeout)
+	defer cancel()
+
+	return locker.lockFile(ctx, path, exclusive)
+}
@@ -0,0 +1,70 @@
+package libhosty
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestHostsFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "hosts")
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestNew(t *testing.T) {
+	h := New(WithDialect(DialectWindows), WithLineLimits(2, 0), WithPreservation(PreserveRaw), WithStrict(true))
+
+	if h.Dialect.Name != DialectWindows.Name || h.MaxHostnamesPerLine != 2 || h.Preserve != PreserveRaw || !h.strict {
+		t.Fatalf("options not applied: %+v", h)
+	}
+
+	if len(h.HostsFileLines) != 0 || h.Path != "" {
+		t.Fatalf("new hosts file should be empty: %+v", h)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := writeTestHostsFile(t, "127.0.0.1 localhost\nnot-an-address line\n")
+
+	h, err := Open(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.Path != path || len(h.HostsFileLines) != 3 || h.HostsFileLines[1].Type != LineTypeUnknown {
+		t.Fatalf("unexpected hosts file: %+v", h)
+	}
+
+	if _, err := Open(context.Background(), path, WithStrict(true)); err == nil {
+		t.Fatal("should fail in strict mode")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := Open(ctx, path); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wants %v got %v", context.Canceled, err)
+	}
+}
+
+func TestPreserveRaw(t *testing.T) {
+	h, err := InitFromString("127.0.0.1\tlocalhost\t# loopback\n10.0.0.1  a.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Preserve = PreserveRaw
+	h.AddHostsFileLine("10.0.0.1", "b.local", "")
+
+	w := "127.0.0.1\tlocalhost\t# loopback\n10.0.0.1         a.local b.local"
+	if got := h.RenderHostsFile(); got != w {
+		t.Fatalf("wants %q got %q", w, got)
+	}
+}
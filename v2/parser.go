@@ -1,31 +1,70 @@
 package libhosty
 
 import (
-	"net"
-	"os"
+	"context"
 	"strings"
 )
 
 // ParseHostsFile parse a hosts file from the given location.
 // error is not nil if something goes wrong
 func ParseHostsFile(path string) ([]HostsFileLine, error) {
-	byteData, err := os.ReadFile(path)
+	return ParseHostsFileFS(OSFS{}, path)
+}
+
+// ParseHostsFileFS parse a hosts file from the given location on the given filesystem.
+// error is not nil if something goes wrong
+func ParseHostsFileFS(fsys FS, path string) ([]HostsFileLine, error) {
+	byteData, err := fsys.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return parser(byteData)
+	return parser(byteData, defaultParserConfig())
 }
 
 // ParseHostsFileFromString parse a hosts file from a given string.
 // error is not nil if something goes wrong
 func ParseHostsFileFromString(stringData string) ([]HostsFileLine, error) {
 	bytesData := []byte(stringData)
-	return parser(bytesData)
+	return parser(bytesData, defaultParserConfig())
+}
+
+// parserConfig holds the configuration used by the parser
+type parserConfig struct {
+	// limits applied to address lines
+	limits lineLimits
+
+	// dialect of the parsed file
+	dialect Dialect
+
+	// if true, unknown lines make the parser fail
+	strict bool
 }
 
+// defaultParserConfig returns the configuration for the running system
+func defaultParserConfig() parserConfig {
+	return (&HostsFile{}).parserConfig()
+}
+
+// parserConfig returns the parser configuration for the HostsFile
+func (h *HostsFile) parserConfig() parserConfig {
+	return parserConfig{
+		limits:  h.limits(),
+		dialect: h.dialect(),
+		strict:  h.strict,
+	}
+}
+
+// parseCheckInterval defines how many lines are parsed between two context checks
+const parseCheckInterval = 1024
+
 // parser, the line parser
-func parser(bytesData []byte) ([]HostsFileLine, error) {
+func parser(bytesData []byte, cfg parserConfig) ([]HostsFileLine, error) {
+	return parserContext(context.Background(), bytesData, cfg)
+}
+
+// parserContext, the line parser, stops if ctx is done
+func parserContext(ctx context.Context, bytesData []byte, cfg parserConfig) ([]HostsFileLine, error) {
 	// normalize input
 	byteDataNormalized := strings.Replace(string(bytesData), "\r\n", "\n", -1)
 
@@ -36,7 +75,14 @@ func parser(bytesData []byte) ([]HostsFileLine, error) {
 	hostsFileLines := make([]HostsFileLine, 0)
 
 	// iterate file lines
-	for _, line := range fileLines {
+	for row, line := range fileLines {
+		// large files can take a while, give up if the context is done
+		if row%parseCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
 		// instantiate a new HostsFileLine
 		curLine := HostsFileLine{
 			Type:        0,
@@ -52,6 +98,7 @@ func parser(bytesData []byte) ([]HostsFileLine, error) {
 
 		// save a raw version of the line, after only TrimSpace sanitization
 		curLine.Raw = rawLine
+		curLine.untrimmed = line
 
 		// check if it's an empty line
 		if rawLine == "" {
@@ -64,14 +111,10 @@ func parser(bytesData []byte) ([]HostsFileLine, error) {
 		if strings.HasPrefix(rawLine, "#") {
 			// this can be a comment or a commented host line
 			// ensure to remove every # char at the beginning of the line
-			// keep track of how many # has been removed
-			hashCounter := 0
-			for !strings.HasPrefix(rawLine, "#") {
+			for strings.HasPrefix(rawLine, "#") {
 				rawLine = strings.TrimPrefix(rawLine, "#")
 				// also trim spaces to avoid "# #" situations
 				rawLine = strings.TrimSpace(rawLine)
-				// increment hash counter
-				hashCounter++
 			}
 
 			// this can be a hashes, comment or commented hosts line
@@ -89,21 +132,10 @@ func parser(bytesData []byte) ([]HostsFileLine, error) {
 
 			// try to parse 1st field as an ip address
 			// if address is nil this line is a comment
-			if address := net.ParseIP(rawLineParts[0]); address == nil {
-				comment := rawLine
-
-				// mark line as comment, normalize and save comment
+			if address, _ := cfg.dialect.parseAddress(rawLineParts[0]); address == nil || len(rawLineParts) < 2 {
+				// mark line as comment and save comment
 				curLine.Type = LineTypeComment
-
-				// since there can be more than one hash, remove those in excess
-				for !strings.HasPrefix(rawLine, "#") {
-					comment = strings.TrimPrefix(comment, "#")
-					// also trim spaces to avoid "# #" situations
-					rawLine = strings.TrimSpace(rawLine)
-				}
-
-				// save comment
-				curLine.Comment = comment
+				curLine.Comment = rawLine
 				hostsFileLines = append(hostsFileLines, curLine)
 				continue
 			}
@@ -122,7 +154,7 @@ func parser(bytesData []byte) ([]HostsFileLine, error) {
 
 		// if we have a comment, trim spaces and save it
 		if len(rawLineSplit) > 1 {
-			curLine.Comment = strings.TrimSpace(rawLineSplit[1])
+			curLine.Comment, curLine.Annotations = parseAnnotations(strings.TrimSpace(rawLineSplit[1]))
 		}
 
 		// split the effective line by spaces
@@ -135,61 +167,34 @@ func parser(bytesData []byte) ([]HostsFileLine, error) {
 			rawAddress := strings.TrimSpace(addressAndHostnames[0])
 
 			// parse address to ensure we have a valid address line
-			if address := net.ParseIP(rawAddress); address != nil {
+			if address, zone := cfg.dialect.parseAddress(rawAddress); address != nil {
 				// set linetype as address and save it
 				curLine.Type = LineTypeAddress
 				curLine.Address = address
+				curLine.Zone = zone
 
 				// parse and lower case all hostnames
 				for _, hostname := range addressAndHostnames[1:] {
-					// sanitize hostname
-					rawHostname := strings.TrimSpace(hostname)
-
-					// if there are 6 or less hostnames, just proceede
-					if len(rawHostname) <= 6 {
-						// add hostname to hostnames slice
-						curLine.Hostnames = append(curLine.Hostnames, strings.ToLower(rawHostname))
-						hostsFileLines = append(hostsFileLines, curLine)
-					} else {
-						// if more than 6 we need to generate 2 lines
-						// save first 6 hostnames to current line
-						// save other to new line
-
-						// get a new line (copy of currentLine, except for Hostnames and Raw)
-						newLine := HostsFileLine{
-							Type:        curLine.Type,
-							Address:     curLine.Address,
-							Hostnames:   make([]string, 0),
-							Raw:         "",
-							Comment:     curLine.Comment,
-							IsCommented: curLine.IsCommented,
-						}
-
-						// save first 6 hostnames
-						curLine.Hostnames = append(curLine.Hostnames, strings.ToLower(rawHostname[:6]))
-						// append current line
-						hostsFileLines = append(hostsFileLines, curLine)
-
-						// save other hostnames
-						newLine.Hostnames = append(newLine.Hostnames, rawHostname[6:])
-						// update Raw field
-						newLine.Raw = lineFormatter(newLine)
-						// append new line
-						hostsFileLines = append(hostsFileLines, newLine)
-					}
+					curLine.Hostnames = append(curLine.Hostnames, strings.ToLower(hostname))
 				}
 
+				// lines exceeding limits are split in multiple lines
+				hostsFileLines = append(hostsFileLines, splitHostsFileLine(curLine, cfg.limits)...)
+
 				// we got a line, go on to the next one
 				continue
 			}
 		}
 
+		// in strict mode, unknown lines are errors
+		if cfg.strict {
+			return nil, ErrUnknownLine(row, curLine.Raw)
+		}
+
 		// if we can't figure out what this line is mark it as unknown
 		curLine.Type = LineTypeUnknown
+		hostsFileLines = append(hostsFileLines, curLine)
 	}
 
-	// normalize slice
-	hostsFileLines = hostsFileLines[:]
-
 	return hostsFileLines, nil
 }
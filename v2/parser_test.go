@@ -100,3 +100,42 @@ func TestParseHostsFileFromString(t *testing.T) {
 		}
 	}
 }
+
+func TestParserLineTypes(t *testing.T) {
+	hfl, err := ParseHostsFileFromString(customHostsFile + "\n\t\tnot-an-address line")
+	if err != nil {
+		t.Fatalf("error parsing customHostsFile: %s", err)
+	}
+
+	wants := []LineType{
+		LineTypeComment,
+		LineTypeComment,
+		LineTypeAddress,
+		LineTypeAddress,
+		LineTypeAddress,
+		LineTypeAddress,
+		LineTypeAddress,
+		LineTypeAddress,
+		LineTypeAddress,
+		LineTypeUnknown,
+	}
+
+	if len(hfl) != len(wants) {
+		t.Fatalf("wants %d lines got %d", len(wants), len(hfl))
+	}
+
+	for idx, w := range wants {
+		if hfl[idx].Type != w {
+			t.Fatalf("line %d: wants %s got %s", idx, w, hfl[idx].Type)
+		}
+	}
+
+	// commented address line
+	if !hfl[5].IsCommented || hfl[5].Hostnames[0] != "commented.evil.domain" || hfl[5].Comment != "with comments" {
+		t.Fatalf("unexpected commented line: %v", hfl[5])
+	}
+
+	if hfl[0].Comment != "Custom hosts file" {
+		t.Fatalf("wants %q got %q", "Custom hosts file", hfl[0].Comment)
+	}
+}
@@ -0,0 +1,375 @@
+package libhosty
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// section markers, as comment lines: # BEGIN name / # END name
+	sectionBegin = "BEGIN"
+	sectionEnd   = "END"
+)
+
+// PolicyRule define a safe type for the policy rule violated by a mutation
+type PolicyRule int
+
+const (
+	//RuleProtectedEntry the mutation removes or alters a protected entry
+	RuleProtectedEntry PolicyRule = iota
+
+	//RuleProtectedSection the mutation changes a protected section
+	RuleProtectedSection
+
+	//RuleDeny the mutation maps a denied hostname to an address not allowed for it
+	RuleDeny
+)
+
+func (r PolicyRule) String() string {
+	switch r {
+	case RuleProtectedEntry:
+		return "rule-protected-entry"
+	case RuleProtectedSection:
+		return "rule-protected-section"
+	case RuleDeny:
+		return "rule-deny"
+	default:
+		return "rule-unknown"
+	}
+}
+
+// DenyRule blocks mapping a domain, and its subdomains, to addresses other than the allowed ones
+type DenyRule struct {
+	//Domain is the protected domain, subdomains are protected too
+	Domain string
+
+	//Allow are the addresses or CIDR networks the domain can be mapped to, empty means none
+	Allow []string
+
+	//Reason is reported in the PolicyViolationError
+	Reason string
+}
+
+// allows reports whether the rule allows mapping to ip
+func (r DenyRule) allows(ip net.IP) bool {
+	for _, a := range r.Allow {
+		if _, network, err := net.ParseCIDR(a); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		if net.IP.Equal(net.ParseIP(a), ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Policy is a Hook protecting entries and sections of a hosts file and enforcing deny rules.
+// protected entries can have hostnames added and comments changed, but cannot be removed,
+// commented, or lose their address or hostnames.
+// protected sections are delimited by "# BEGIN name" and "# END name" comment lines,
+// and cannot be changed at all
+type Policy struct {
+	//ProtectedHostnames are hostnames whose lines are protected
+	ProtectedHostnames []string
+
+	//ProtectLoopback protects every line with a loopback address
+	ProtectLoopback bool
+
+	//ProtectedSections are the names of the protected sections
+	ProtectedSections []string
+
+	//Deny are the deny rules checked on every added or changed mapping
+	Deny []DenyRule
+}
+
+// DefaultPolicy returns a Policy protecting localhost and broadcasthost entries
+func DefaultPolicy() *Policy {
+	return &Policy{
+		ProtectedHostnames: []string{
+			"localhost",
+			"localhost.localdomain",
+			"broadcasthost",
+			"ip6-localhost",
+			"ip6-loopback",
+		},
+	}
+}
+
+// WithPolicy enforces the given policy on every mutation
+func WithPolicy(p *Policy) Option {
+	return WithHook(p)
+}
+
+// Before vetoes m with a PolicyViolationError if it breaks the policy
+func (p *Policy) Before(h *HostsFile, m Mutation) error {
+	h.Lock()
+	sections := p.sectionRows(h.HostsFileLines)
+	h.Unlock()
+
+	switch m.Op {
+	case OpAdd:
+		for i, row := range m.Rows {
+			if name, ok := sections.containsInsert(row); ok {
+				return p.violation(RuleProtectedSection, m.Op, row, m.New[i], "", "section "+name+" is protected")
+			}
+		}
+	case OpReplace:
+		if err := p.checkReplace(m, sections); err != nil {
+			return err
+		}
+	default:
+		for i, row := range m.Rows {
+			if name, ok := sections.contains(row); ok {
+				return p.violation(RuleProtectedSection, m.Op, row, m.Old[i], "", "section "+name+" is protected")
+			}
+
+			if !p.isProtected(m.Old[i]) {
+				continue
+			}
+
+			if m.Op == OpRemove || m.Op == OpComment || !preservesLine(m.Old[i], m.New[i]) {
+				return p.violation(RuleProtectedEntry, m.Op, row, m.Old[i], protectedHostname(p, m.Old[i]), "entry is protected")
+			}
+		}
+	}
+
+	for i, hfl := range m.New {
+		row := -1
+		if i < len(m.Rows) {
+			row = m.Rows[i]
+		}
+
+		if err := p.checkDeny(m.Op, row, hfl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// After does nothing
+func (p *Policy) After(h *HostsFile, m Mutation) {}
+
+// checkReplace ensures every protected line, and every protected section, is kept by a replace
+func (p *Policy) checkReplace(m Mutation, sections hostsSections) error {
+	newSections := p.sectionRows(m.New)
+
+	for row, hfl := range m.Old {
+		if name, ok := sections.contains(row); ok {
+			if !equalHostsFileLinesSlice(sections.lines(m.Old, name), newSections.lines(m.New, name)) {
+				return p.violation(RuleProtectedSection, m.Op, row, hfl, "", "section "+name+" is protected")
+			}
+			continue
+		}
+
+		if !p.isProtected(hfl) {
+			continue
+		}
+
+		kept := false
+		for _, n := range m.New {
+			if n.Type == LineTypeAddress && preservesLine(hfl, n) {
+				kept = true
+				break
+			}
+		}
+
+		if !kept {
+			return p.violation(RuleProtectedEntry, m.Op, row, hfl, protectedHostname(p, hfl), "entry is protected")
+		}
+	}
+
+	return nil
+}
+
+// checkDeny ensures hfl does not map a denied hostname to an address not allowed for it
+func (p *Policy) checkDeny(op Operation, row int, hfl HostsFileLine) error {
+	if hfl.Type != LineTypeAddress || hfl.IsCommented {
+		return nil
+	}
+
+	for _, rule := range p.Deny {
+		domain := normalizeHostname(rule.Domain)
+
+		for _, hn := range hfl.Hostnames {
+			if !matchDomain(hn, domain) || rule.allows(hfl.Address) {
+				continue
+			}
+
+			reason := rule.Reason
+			if reason == "" {
+				reason = fmt.Sprintf("%s cannot be mapped to %s", hn, hfl.Address)
+			}
+
+			return p.violation(RuleDeny, op, row, hfl, hn, reason)
+		}
+	}
+
+	return nil
+}
+
+// isProtected reports whether hfl is a protected entry
+func (p *Policy) isProtected(hfl HostsFileLine) bool {
+	if hfl.Type != LineTypeAddress || hfl.IsCommented {
+		return false
+	}
+
+	if p.ProtectLoopback && hfl.Address.IsLoopback() {
+		return true
+	}
+
+	return protectedHostname(p, hfl) != ""
+}
+
+// violation returns a PolicyViolationError
+func (p *Policy) violation(rule PolicyRule, op Operation, row int, hfl HostsFileLine, hostname, reason string) error {
+	return &PolicyViolationError{
+		Rule:     rule,
+		Op:       op,
+		Row:      row,
+		Line:     hfl,
+		Hostname: hostname,
+		Reason:   reason,
+	}
+}
+
+// protectedHostname returns the first protected hostname of hfl, empty if none
+func protectedHostname(p *Policy, hfl HostsFileLine) string {
+	for _, hn := range hfl.Hostnames {
+		for _, protected := range p.ProtectedHostnames {
+			if normalizeHostname(hn) == normalizeHostname(protected) {
+				return hn
+			}
+		}
+	}
+
+	return ""
+}
+
+// preservesLine reports whether updated keeps the address, the state and every hostname of hfl
+func preservesLine(hfl, updated HostsFileLine) bool {
+	if !sameAddress(updated, hfl.Address, hfl.Zone) || updated.IsCommented != hfl.IsCommented {
+		return false
+	}
+
+	for _, hn := range hfl.Hostnames {
+		if _, found := withoutHostname(updated.Hostnames, hn); !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hostsSection holds the rows of a section, markers included
+type hostsSection struct {
+	name  string
+	begin int
+	end   int
+}
+
+// hostsSections holds the sections of a hosts file
+type hostsSections []hostsSection
+
+// sectionRows returns the protected sections found in lines
+func (p *Policy) sectionRows(lines []HostsFileLine) hostsSections {
+	return findSections(lines, p.ProtectedSections)
+}
+
+// findSections returns the sections named in names found in lines.
+// a section without its END marker extends to the end of the file
+func findSections(lines []HostsFileLine, names []string) hostsSections {
+	res := make(hostsSections, 0)
+
+	for idx, hfl := range lines {
+		name, begin := sectionMarker(hfl)
+		if !begin || !containsFold(names, name) {
+			continue
+		}
+
+		s := hostsSection{name: name, begin: idx, end: len(lines) - 1}
+
+		for end := idx + 1; end < len(lines); end++ {
+			if endName, isBegin := sectionMarker(lines[end]); !isBegin && strings.EqualFold(endName, name) {
+				s.end = end
+				break
+			}
+		}
+
+		res = append(res, s)
+	}
+
+	return res
+}
+
+// containsFold reports whether names contains name, case insensitively
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// contains returns the name of the section holding row
+func (ps hostsSections) contains(row int) (string, bool) {
+	for _, s := range ps {
+		if row >= s.begin && row <= s.end {
+			return s.name, true
+		}
+	}
+
+	return "", false
+}
+
+// containsInsert returns the name of the section a line inserted at row would end up in
+func (ps hostsSections) containsInsert(row int) (string, bool) {
+	for _, s := range ps {
+		if row > s.begin && row <= s.end {
+			return s.name, true
+		}
+	}
+
+	return "", false
+}
+
+// lines returns the lines of the named section
+func (ps hostsSections) lines(lines []HostsFileLine, name string) []HostsFileLine {
+	for _, s := range ps {
+		if strings.EqualFold(s.name, name) {
+			return lines[s.begin : s.end+1]
+		}
+	}
+
+	return nil
+}
+
+// sectionMarker returns the section name of a "# BEGIN name" or "# END name" comment line,
+// begin is true for BEGIN markers. name is empty if hfl is not a marker
+func sectionMarker(hfl HostsFileLine) (name string, begin bool) {
+	if hfl.Type != LineTypeComment {
+		return "", false
+	}
+
+	fields := strings.Fields(hfl.Comment)
+	if len(fields) != 2 {
+		return "", false
+	}
+
+	switch strings.ToUpper(fields[0]) {
+	case sectionBegin:
+		return fields[1], true
+	case sectionEnd:
+		return fields[1], false
+	default:
+		return "", false
+	}
+}
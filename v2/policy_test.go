@@ -0,0 +1,150 @@
+package libhosty
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPolicyProtectedEntry(t *testing.T) {
+	h, err := InitFromString("127.0.0.1 localhost\n255.255.255.255 broadcasthost\n10.0.0.1 db.local\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.AddHook(DefaultPolicy())
+
+	var violation *PolicyViolationError
+
+	if err := h.RemoveHostsFileLinesBySelector(SelectRegexp(`.*`)); !errors.As(err, &violation) {
+		t.Fatalf("expected *PolicyViolationError, got %v", err)
+	}
+
+	if violation.Rule != RuleProtectedEntry || violation.Op != OpRemove || violation.Hostname != "localhost" {
+		t.Fatalf("unexpected violation %v", violation)
+	}
+
+	if len(h.GetHostsFileLinesByRegexp(`.*`)) != 3 {
+		t.Fatal("expected no line to be removed")
+	}
+
+	if err := h.CommentHostsFileLinesBySelector(SelectHostname("broadcasthost")); !errors.As(err, &violation) {
+		t.Fatalf("expected *PolicyViolationError, got %v", err)
+	}
+
+	// adding a hostname to a protected entry is allowed
+	if _, _, err := h.AddHostsFileLine("127.0.0.1", "dev.local", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.RemoveHostsFileLinesBySelector(SelectHostname("db.local")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPolicyProtectLoopback(t *testing.T) {
+	h, err := InitFromString("127.0.1.1 myhost\n10.0.0.1 db.local\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.AddHook(&Policy{ProtectLoopback: true})
+
+	var violation *PolicyViolationError
+	if err := h.RemoveHostsFileLinesBySelector(SelectAddress("127.0.1.1")); !errors.As(err, &violation) {
+		t.Fatalf("expected *PolicyViolationError, got %v", err)
+	}
+}
+
+func TestPolicyProtectedSection(t *testing.T) {
+	h, err := InitFromString("127.0.0.1 localhost\n# BEGIN corp\n10.1.0.1 git.corp\n# END corp\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.AddHook(&Policy{ProtectedSections: []string{"corp"}})
+
+	var violation *PolicyViolationError
+
+	if err := h.RemoveHostsFileLinesBySelector(SelectHostname("git.corp")); !errors.As(err, &violation) {
+		t.Fatalf("expected *PolicyViolationError, got %v", err)
+	}
+
+	if violation.Rule != RuleProtectedSection || violation.Row != 2 {
+		t.Fatalf("unexpected violation %v", violation)
+	}
+
+	// inserting before the END marker changes the section
+	insert := Mutation{Op: OpAdd, Rows: []int{3}, New: []HostsFileLine{{Type: LineTypeEmpty}}}
+	if err := h.hooks[0].Before(h, insert); !errors.As(err, &violation) {
+		t.Fatalf("expected *PolicyViolationError, got %v", err)
+	}
+
+	// appending after it does not
+	if _, _, err := h.AddHostsFileLine("10.1.0.2", "wiki.corp", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// lines outside the section are not protected
+	if err := h.RemoveHostsFileLinesBySelector(SelectHostname("localhost")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPolicyDeny(t *testing.T) {
+	h := New(WithDialect(DialectGlibc), WithPolicy(&Policy{
+		Deny: []DenyRule{
+			{Domain: "sso.example.com", Allow: []string{"192.0.2.0/24"}},
+		},
+	}))
+
+	var violation *PolicyViolationError
+
+	if _, _, err := h.AddHostsFileLine("10.6.6.6", "login.sso.example.com", ""); !errors.As(err, &violation) {
+		t.Fatalf("expected *PolicyViolationError, got %v", err)
+	}
+
+	if violation.Rule != RuleDeny || violation.Hostname != "login.sso.example.com" {
+		t.Fatalf("unexpected violation %v", violation)
+	}
+
+	if _, _, err := h.AddHostsFileLine("192.0.2.10", "login.sso.example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := h.AddHostsFileLine("10.6.6.6", "sso.example.com.evil", ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPolicyLegacyMethods(t *testing.T) {
+	h, err := InitFromString("127.0.0.1 localhost\n10.0.0.1 foo.local\n10.0.0.2 bar.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.AddHook(DefaultPolicy())
+
+	// the selector variant reports the violation and removes nothing
+	var violation *PolicyViolationError
+	if err := h.RemoveHostsFileLinesBySelector(SelectRegexp("local")); !errors.As(err, &violation) || violation.Row != 0 {
+		t.Fatalf("expected a violation at row 0, got %v", err)
+	}
+
+	if len(h.HostsFileLines) != 3 {
+		t.Fatalf("expected no line to be removed, got %v", h.HostsFileLines)
+	}
+
+	// the legacy method leaves the protected entry out
+	h.CommentHostsFileLinesByRegexp("local")
+
+	if h.HostsFileLines[0].IsCommented || !h.HostsFileLines[1].IsCommented || !h.HostsFileLines[2].IsCommented {
+		t.Fatalf("expected foo.local and bar.local to be commented, got %v", h.HostsFileLines)
+	}
+
+	h.UncommentHostsFileLinesByRegexp("local")
+	h.RemoveHostsFileLinesByRegexp("local")
+
+	if len(h.HostsFileLines) != 1 || h.HostsFileLines[0].Hostnames[0] != "localhost" {
+		t.Fatalf("expected only localhost to be kept, got %v", h.HostsFileLines)
+	}
+}
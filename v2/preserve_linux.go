@@ -0,0 +1,100 @@
+//go:build linux
+
+package libhosty
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// statFileAttrs returns mode, owner and extended attributes of the named file
+func statFileAttrs(name string) (fileAttrs, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return fileAttrs{}, err
+	}
+
+	attrs := fileAttrs{perm: fileModeBits(fi.Mode())}
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		attrs.uid = int(st.Uid)
+		attrs.gid = int(st.Gid)
+		attrs.hasOwner = true
+	}
+
+	attrs.xattrs, err = readXattrs(name)
+	if err != nil {
+		return fileAttrs{}, err
+	}
+
+	return attrs, nil
+}
+
+// readXattrs returns the extended attributes of the named file,
+// nil if the filesystem does not support them
+func readXattrs(name string) (map[string][]byte, error) {
+	size, err := syscall.Listxattr(name, nil)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: "listxattr", Path: name, Err: err}
+	}
+	if size == 0 {
+		return nil, nil
+	}
+
+	buf := make([]byte, size)
+
+	size, err = syscall.Listxattr(name, buf)
+	if err != nil {
+		return nil, &os.PathError{Op: "listxattr", Path: name, Err: err}
+	}
+
+	xattrs := make(map[string][]byte)
+
+	// names are NUL terminated
+	for _, attr := range strings.Split(strings.TrimRight(string(buf[:size]), "\x00"), "\x00") {
+		size, err := syscall.Getxattr(name, attr, nil)
+		if err != nil {
+			return nil, &os.PathError{Op: "getxattr", Path: name, Err: err}
+		}
+
+		value := make([]byte, size)
+
+		size, err = syscall.Getxattr(name, attr, value)
+		if err != nil {
+			return nil, &os.PathError{Op: "getxattr", Path: name, Err: err}
+		}
+
+		xattrs[attr] = value[:size]
+	}
+
+	return xattrs, nil
+}
+
+// applyFileAttrs sets owner, mode and extended attributes on f.
+// security.* attributes, like SELinux labels, require privileges
+func applyFileAttrs(f *os.File, attrs fileAttrs) error {
+	// chown clears setuid and setgid bits, so it goes first
+	if attrs.hasOwner {
+		if err := f.Chown(attrs.uid, attrs.gid); err != nil {
+			return err
+		}
+	}
+
+	if err := f.Chmod(attrs.perm); err != nil {
+		return err
+	}
+
+	// POSIX ACLs are extended attributes too, and are set after the mode
+	for attr, value := range attrs.xattrs {
+		if err := syscall.Setxattr(f.Name(), attr, value, 0); err != nil {
+			return &os.PathError{Op: "setxattr", Path: f.Name(), Err: err}
+		}
+	}
+
+	return nil
+}
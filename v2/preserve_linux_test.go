@@ -0,0 +1,49 @@
+//go:build linux
+
+package libhosty
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestWriteHostsFilePreserveXattrs(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "hosts")
+
+	if err := os.WriteFile(p, []byte("127.0.0.1 localhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := syscall.Setxattr(p, "user.libhosty", []byte("test"), 0); err != nil {
+		t.Skipf("extended attributes not supported: %v", err)
+	}
+
+	before, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := newTestHostsFile(t, [3]string{"10.0.0.1", "db", ""}).WriteHostsFileTo(p); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if os.SameFile(before, after) {
+		t.Fatal("expected the file to be replaced")
+	}
+
+	xattrs, err := readXattrs(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(xattrs["user.libhosty"]) != "test" {
+		t.Fatalf("expected user.libhosty to be kept, got %v", xattrs)
+	}
+}
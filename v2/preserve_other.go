@@ -0,0 +1,20 @@
+//go:build !unix
+
+package libhosty
+
+import "os"
+
+// statFileAttrs returns the mode of the named file, owners are not supported
+func statFileAttrs(name string) (fileAttrs, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return fileAttrs{}, err
+	}
+
+	return fileAttrs{perm: fileModeBits(fi.Mode())}, nil
+}
+
+// applyFileAttrs sets the mode on f
+func applyFileAttrs(f *os.File, attrs fileAttrs) error {
+	return f.Chmod(attrs.perm)
+}
@@ -0,0 +1,38 @@
+//go:build unix && !linux
+
+package libhosty
+
+import (
+	"os"
+	"syscall"
+)
+
+// statFileAttrs returns mode and owner of the named file
+func statFileAttrs(name string) (fileAttrs, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return fileAttrs{}, err
+	}
+
+	attrs := fileAttrs{perm: fileModeBits(fi.Mode())}
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		attrs.uid = int(st.Uid)
+		attrs.gid = int(st.Gid)
+		attrs.hasOwner = true
+	}
+
+	return attrs, nil
+}
+
+// applyFileAttrs sets owner and mode on f
+func applyFileAttrs(f *os.File, attrs fileAttrs) error {
+	// chown clears setuid and setgid bits, so it goes first
+	if attrs.hasOwner {
+		if err := f.Chown(attrs.uid, attrs.gid); err != nil {
+			return err
+		}
+	}
+
+	return f.Chmod(attrs.perm)
+}
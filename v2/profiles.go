@@ -0,0 +1,246 @@
+package libhosty
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/exp/slices"
+)
+
+// AnnotationProfile is the annotation holding the profiles an entry belongs to, comma separated
+const AnnotationProfile = "profile"
+
+// profilesPrefix starts the comment line holding the active profiles:
+// # libhosty-profiles: active=staging
+const profilesPrefix = "libhosty-profiles:"
+
+// Profiles returns the names of every profile found in the hosts file, sorted
+func (h *HostsFile) Profiles() []string {
+	h.Lock()
+	defer h.Unlock()
+
+	res := make([]string, 0)
+
+	for _, hfl := range h.HostsFileLines {
+		for _, p := range lineProfiles(hfl) {
+			if !slices.Contains(res, p) {
+				res = append(res, p)
+			}
+		}
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// ActiveProfiles returns the names of the active profiles, as persisted in the hosts file
+func (h *HostsFile) ActiveProfiles() []string {
+	h.Lock()
+	defer h.Unlock()
+
+	_, active := findProfilesLine(h.HostsFileLines)
+
+	return active
+}
+
+// ActivateProfile uncomments the entries of the named profile and deactivates the other profiles
+// mapping the same hostnames, commenting out their entries.
+// entries without profile are left alone.
+// the active profiles are persisted in a "# libhosty-profiles: active=..." comment line,
+// added on top of the file if missing. the changes are applied as an OpReplace mutation.
+// error is ErrInvalidProfile if name cannot be a profile name,
+// ErrProfileNotFound if no entry belongs to the profile,
+// or not nil if a hook vetoes the change
+func (h *HostsFile) ActivateProfile(name string) error {
+	if err := validateProfile(name); err != nil {
+		return err
+	}
+
+	current := h.snapshot()
+	lines := cloneHostsFileLines(current)
+
+	hostnames := make(map[string]bool)
+	found := false
+
+	for _, hfl := range lines {
+		if hfl.Type == LineTypeAddress && slices.Contains(lineProfiles(hfl), name) {
+			found = true
+			for _, hn := range hfl.Hostnames {
+				hostnames[normalizeHostname(hn)] = true
+			}
+		}
+	}
+
+	if !found {
+		return ErrProfileNotFound(name)
+	}
+
+	// profiles mapping the same hostnames conflict with the activated one
+	deactivated := make([]string, 0)
+
+	for _, hfl := range lines {
+		profiles := lineProfiles(hfl)
+		if hfl.Type != LineTypeAddress || hfl.IsCommented || slices.Contains(profiles, name) || !mapsAnyHostname(hfl, hostnames) {
+			continue
+		}
+
+		deactivated = append(deactivated, profiles...)
+	}
+
+	for i, hfl := range lines {
+		if hfl.Type != LineTypeAddress {
+			continue
+		}
+
+		profiles := lineProfiles(hfl)
+
+		if slices.Contains(profiles, name) {
+			lines[i].IsCommented = false
+			continue
+		}
+
+		for _, p := range profiles {
+			if slices.Contains(deactivated, p) {
+				lines[i].IsCommented = true
+				break
+			}
+		}
+	}
+
+	return h.replaceProfiles(current, lines, func(active []string) []string {
+		res := []string{name}
+		for _, p := range active {
+			if p != name && !slices.Contains(deactivated, p) {
+				res = append(res, p)
+			}
+		}
+
+		return res
+	})
+}
+
+// DeactivateProfile comments out the entries of the named profile.
+// the changes are applied as an OpReplace mutation.
+// error is ErrInvalidProfile if name cannot be a profile name,
+// ErrProfileNotFound if no entry belongs to the profile,
+// or not nil if a hook vetoes the change
+func (h *HostsFile) DeactivateProfile(name string) error {
+	if err := validateProfile(name); err != nil {
+		return err
+	}
+
+	current := h.snapshot()
+	lines := cloneHostsFileLines(current)
+
+	found := false
+
+	for i, hfl := range lines {
+		if hfl.Type == LineTypeAddress && slices.Contains(lineProfiles(hfl), name) {
+			found = true
+			lines[i].IsCommented = true
+		}
+	}
+
+	if !found {
+		return ErrProfileNotFound(name)
+	}
+
+	return h.replaceProfiles(current, lines, func(active []string) []string {
+		return slices.DeleteFunc(active, func(p string) bool {
+			return p == name
+		})
+	})
+}
+
+// replaceProfiles updates the active profiles line of lines with update,
+// then replaces the hosts file content, old, with lines.
+// lines are the lines of old commented or uncommented, only the changed ones are rendered again
+func (h *HostsFile) replaceProfiles(old, lines []HostsFileLine, update func(active []string) []string) error {
+	for i := range lines {
+		if lines[i].IsCommented != old[i].IsCommented {
+			lines[i].Raw = lineFormatter(lines[i])
+		}
+	}
+
+	row, active := findProfilesLine(lines)
+
+	state := HostsFileLine{
+		Type:    LineTypeComment,
+		Comment: profilesPrefix + " active=" + strings.Join(update(active), ","),
+	}
+	state.Raw = lineFormatter(state)
+
+	if row < 0 {
+		lines = slices.Insert(lines, 0, state)
+	} else if lines[row].Comment != state.Comment {
+		lines[row] = state
+	}
+
+	if equalHostsFileLinesSlice(lines, old) {
+		return nil
+	}
+
+	return h.replaceLines(old, lines)
+}
+
+// findProfilesLine returns the row of the active profiles line and the active profiles,
+// row is -1 if there is no such line
+func findProfilesLine(lines []HostsFileLine) (int, []string) {
+	for idx, hfl := range lines {
+		if hfl.Type != LineTypeComment || !strings.HasPrefix(hfl.Comment, profilesPrefix) {
+			continue
+		}
+
+		active := make([]string, 0)
+
+		for _, f := range strings.Fields(strings.TrimPrefix(hfl.Comment, profilesPrefix)) {
+			if k, v, _ := strings.Cut(f, "="); k == "active" {
+				active = append(active, splitProfiles(v)...)
+			}
+		}
+
+		return idx, active
+	}
+
+	return -1, []string{}
+}
+
+// validateProfile ensures name can be stored in the profile annotation and in the active profiles line
+func validateProfile(name string) error {
+	if name == "" || strings.ContainsAny(name, ",#=") || strings.ContainsFunc(name, unicode.IsSpace) {
+		return ErrInvalidProfile(name)
+	}
+
+	return nil
+}
+
+// lineProfiles returns the profiles the given line belongs to
+func lineProfiles(hfl HostsFileLine) []string {
+	return splitProfiles(hfl.Annotations[AnnotationProfile])
+}
+
+// splitProfiles splits a comma separated list of profiles, dropping empty names
+func splitProfiles(value string) []string {
+	res := make([]string, 0)
+
+	for _, p := range strings.Split(value, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			res = append(res, p)
+		}
+	}
+
+	return res
+}
+
+// mapsAnyHostname reports whether hfl maps one of the given normalized hostnames
+func mapsAnyHostname(hfl HostsFileLine, hostnames map[string]bool) bool {
+	for _, hn := range hfl.Hostnames {
+		if hostnames[normalizeHostname(hn)] {
+			return true
+		}
+	}
+
+	return false
+}
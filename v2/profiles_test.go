@@ -0,0 +1,188 @@
+package libhosty
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestProfiles(t *testing.T) {
+	h, err := InitFromString(strings.Join([]string{
+		"127.0.0.1 localhost",
+		"127.0.0.1 api.example.com # libhosty: profile=local",
+		"# 10.1.0.1 api.example.com # libhosty: profile=staging",
+		"# 10.1.0.2 db.example.com # libhosty: profile=staging",
+		"# 10.2.0.1 api.example.com # libhosty: profile=production",
+		"10.9.0.1 tools.example.com # libhosty: profile=tools",
+	}, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := h.Profiles(); !slices.Equal(got, []string{"local", "production", "staging", "tools"}) {
+		t.Fatalf("unexpected profiles %v", got)
+	}
+
+	if got := h.ActiveProfiles(); len(got) != 0 {
+		t.Fatalf("expected no active profile, got %v", got)
+	}
+
+	if err := h.ActivateProfile("staging"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := h.ActiveProfiles(); !slices.Equal(got, []string{"staging"}) {
+		t.Fatalf("unexpected active profiles %v", got)
+	}
+
+	// the state line is added on top of the file
+	if h.HostsFileLines[0].Comment != "libhosty-profiles: active=staging" {
+		t.Fatalf("unexpected state line %v", h.HostsFileLines[0])
+	}
+
+	// each hostname has a single active mapping
+	for _, hn := range []string{"localhost", "api.example.com", "db.example.com", "tools.example.com"} {
+		active := make([]*HostsFileLine, 0)
+		for _, hfl := range h.GetHostsFileLinesByHostname(hn) {
+			if !hfl.IsCommented {
+				active = append(active, hfl)
+			}
+		}
+
+		if len(active) != 1 {
+			t.Fatalf("%s: expected a single active line, got %v", hn, active)
+		}
+
+		if hn == "api.example.com" && active[0].Address.String() != "10.1.0.1" {
+			t.Fatalf("expected staging api, got %s", active[0].Address)
+		}
+	}
+
+	// local has been deactivated
+	if !h.HostsFileLines[2].IsCommented {
+		t.Fatalf("expected local entry to be commented, got %v", h.HostsFileLines[2])
+	}
+
+	// the state survives a round trip
+	reparsed, err := InitFromString(h.RenderHostsFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reparsed.ActivateProfile("tools"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := reparsed.ActiveProfiles(); !slices.Equal(got, []string{"tools", "staging"}) {
+		t.Fatalf("unexpected active profiles %v", got)
+	}
+
+	if err := reparsed.ActivateProfile("production"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := reparsed.ActiveProfiles(); !slices.Equal(got, []string{"production", "tools"}) {
+		t.Fatalf("unexpected active profiles %v", got)
+	}
+
+	// every staging entry is commented, not only the conflicting one
+	for _, hfl := range reparsed.GetHostsFileLinesByAnnotation(AnnotationProfile, "staging") {
+		if !hfl.IsCommented {
+			t.Fatalf("expected staging entries to be commented, got %v", hfl)
+		}
+	}
+
+	if err := reparsed.DeactivateProfile("tools"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := reparsed.ActiveProfiles(); !slices.Equal(got, []string{"production"}) {
+		t.Fatalf("unexpected active profiles %v", got)
+	}
+
+	if err := reparsed.ActivateProfile("unknown"); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Fatalf("expected a profile not found error, got %v", err)
+	}
+}
+
+func TestAddWithProfile(t *testing.T) {
+	h := New(WithDialect(DialectGlibc))
+
+	if _, _, err := h.AddHostsFileLineWithOptions("10.1.0.1", "api", "", AddOptions{Profile: "staging"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := h.Profiles(); !slices.Equal(got, []string{"staging"}) {
+		t.Fatalf("unexpected profiles %v", got)
+	}
+}
+
+func TestActivateProfileVeto(t *testing.T) {
+	h, err := InitFromString("# 10.1.0.1 api # libhosty: profile=staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errVeto := errors.New("veto")
+	h.AddHook(HookFuncs{BeforeFunc: func(h *HostsFile, m Mutation) error { return errVeto }})
+
+	if err := h.ActivateProfile("staging"); !errors.Is(err, errVeto) {
+		t.Fatalf("expected veto, got %v", err)
+	}
+
+	if !h.HostsFileLines[0].IsCommented || len(h.ActiveProfiles()) != 0 {
+		t.Fatal("expected no change")
+	}
+}
+
+func TestActivateProfileKeepsFormatting(t *testing.T) {
+	h, err := InitFromString(strings.Join([]string{
+		"##### my header",
+		"127.0.0.1\tlocalhost    # loopback",
+		"# 10.1.0.1 api # libhosty: profile=staging",
+	}, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Preserve = PreserveRaw
+
+	if err := h.ActivateProfile("staging"); err != nil {
+		t.Fatal(err)
+	}
+
+	rendered := h.RenderHostsFile()
+	for _, raw := range []string{"##### my header", "127.0.0.1\tlocalhost    # loopback"} {
+		if !strings.Contains(rendered, raw) {
+			t.Fatalf("expected %q to be preserved, got %q", raw, rendered)
+		}
+	}
+
+	if h.HostsFileLines[3].IsCommented || strings.HasPrefix(h.HostsFileLines[3].Raw, "#") {
+		t.Fatalf("expected the staging entry to be enabled, got %q", h.HostsFileLines[3].Raw)
+	}
+}
+
+func TestInvalidProfile(t *testing.T) {
+	h := New(WithDialect(DialectGlibc))
+
+	for _, name := range []string{"my staging", "a,b", "a#b", "a=b"} {
+		if _, _, err := h.AddHostsFileLineWithOptions("10.1.0.1", "api", "", AddOptions{Profile: name}); err == nil || !strings.Contains(err.Error(), "invalid profile") {
+			t.Fatalf("%q: expected an invalid profile error, got %v", name, err)
+		}
+
+		if err := h.ActivateProfile(name); err == nil || !strings.Contains(err.Error(), "invalid profile") {
+			t.Fatalf("%q: expected an invalid profile error, got %v", name, err)
+		}
+
+		if err := h.DeactivateProfile(name); err == nil || !strings.Contains(err.Error(), "invalid profile") {
+			t.Fatalf("%q: expected an invalid profile error, got %v", name, err)
+		}
+	}
+
+	if len(h.HostsFileLines) != 0 {
+		t.Fatalf("expected no line to be added, got %v", h.HostsFileLines)
+	}
+}
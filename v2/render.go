@@ -1,20 +1,40 @@
 package libhosty
 
-import "strings"
+import (
+	"net"
+	"strings"
 
-// RenderHostsFile render and returns the hosts file with the lineFormatter() routine
+	"golang.org/x/exp/slices"
+)
+
+// RenderHostsFile render and returns the hosts file with the lineFormatter() routine,
+// line endings follow the configured dialect.
+// with PreserveRaw, unchanged lines are rendered as they were in the original file
 func (h *HostsFile) RenderHostsFile() string {
+	return h.renderHostsFileLines(h.HostsFileLines)
+}
+
+// renderHostsFileLines renders lines as RenderHostsFile does
+func (h *HostsFile) renderHostsFileLines(lines []HostsFileLine) string {
+	d := h.dialect()
+	cfg := h.parserConfig()
+
 	// allocate a buffer for file lines
 	var sliceBuffer []string
 
-	// iterate HostsFileLines and popolate the buffer with formatted lines
-	for _, l := range h.HostsFileLines {
+	// iterate lines and popolate the buffer with formatted lines
+	for _, l := range lines {
+		if h.Preserve == PreserveRaw && lineUnchanged(l, cfg) {
+			sliceBuffer = append(sliceBuffer, l.Raw)
+			continue
+		}
+
 		sliceBuffer = append(sliceBuffer, lineFormatter(l))
 	}
 
 	// strings.Join() prevent the last line from being a new blank line
 	// as opposite to a for loop with fmt.Printf(buffer + '\n')
-	return strings.Join(sliceBuffer, "\n")
+	return strings.Join(sliceBuffer, d.lineEnding())
 }
 
 // RenderHostsFileLine render and returns the given hosts line with the lineFormatter() routine
@@ -26,3 +46,43 @@ func (h *HostsFile) RenderHostsFileLine(row int) string {
 
 	return ""
 }
+
+// lineUnchanged reports whether the Raw field of the given line still describes it
+func lineUnchanged(hfl HostsFileLine, cfg parserConfig) bool {
+	if hfl.Raw == "" {
+		return hfl.Type == LineTypeEmpty
+	}
+
+	// raw lines are checked one by one, unknown lines are kept
+	cfg.strict = false
+
+	parsed, err := parser([]byte(hfl.Raw), cfg)
+	if err != nil || len(parsed) != 1 {
+		return false
+	}
+
+	return equalHostsFileLines(parsed[0], hfl)
+}
+
+// equalHostsFileLines reports whether two lines hold the same data, regardless of formatting
+func equalHostsFileLines(a, b HostsFileLine) bool {
+	if a.Type != b.Type {
+		return false
+	}
+
+	switch a.Type {
+	case LineTypeUnknown:
+		return a.Raw == b.Raw
+	case LineTypeComment:
+		return a.Comment == b.Comment
+	case LineTypeAddress:
+		return net.IP.Equal(a.Address, b.Address) &&
+			a.Zone == b.Zone &&
+			a.IsCommented == b.IsCommented &&
+			a.Comment == b.Comment &&
+			equalAnnotations(a.Annotations, b.Annotations) &&
+			slices.Equal(a.Hostnames, b.Hostnames)
+	default:
+		return true
+	}
+}
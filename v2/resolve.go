@@ -0,0 +1,194 @@
+package libhosty
+
+import (
+	"net"
+	"sort"
+)
+
+// Family define a safe type for address family enumeration
+type Family int
+
+const (
+	//FamilyAny resolves both IPv4 and IPv6 addresses
+	FamilyAny Family = 0
+
+	//FamilyIPv4 resolves IPv4 addresses only
+	FamilyIPv4 Family = 4
+
+	//FamilyIPv6 resolves IPv6 addresses only
+	FamilyIPv6 Family = 6
+)
+
+func (f Family) String() string {
+	switch f {
+	case FamilyIPv4:
+		return "family-ipv4"
+	case FamilyIPv6:
+		return "family-ipv6"
+	default:
+		return "family-any"
+	}
+}
+
+// matches reports whether ip belongs to the family
+func (f Family) matches(ip net.IP) bool {
+	switch f {
+	case FamilyIPv4:
+		return ip.To4() != nil
+	case FamilyIPv6:
+		return ip.To4() == nil
+	default:
+		return true
+	}
+}
+
+// ResolveReason define a safe type for the reason a candidate won or lost
+type ResolveReason int
+
+const (
+	//ReasonFirstMatch the candidate is the first match for its family
+	ReasonFirstMatch ResolveReason = iota
+
+	//ReasonMultipleMatches the candidate is returned because the dialect returns every match
+	ReasonMultipleMatches
+
+	//ReasonShadowed the candidate is hidden by a previous match, the dialect stops at the first one
+	ReasonShadowed
+
+	//ReasonDuplicate the candidate address is already returned by a previous match
+	ReasonDuplicate
+
+	//ReasonWrongFamily the candidate address does not belong to the requested family
+	ReasonWrongFamily
+
+	//ReasonCommented the candidate line is commented out, resolvers ignore it
+	ReasonCommented
+)
+
+func (r ResolveReason) String() string {
+	switch r {
+	case ReasonFirstMatch:
+		return "first-match"
+	case ReasonMultipleMatches:
+		return "multiple-matches"
+	case ReasonShadowed:
+		return "shadowed"
+	case ReasonDuplicate:
+		return "duplicate"
+	case ReasonWrongFamily:
+		return "wrong-family"
+	case ReasonCommented:
+		return "commented"
+	default:
+		return "unknown"
+	}
+}
+
+// ResolveCandidate holds a line matching the resolved name
+type ResolveCandidate struct {
+	//Row is the index of the line that produced the candidate
+	Row int
+
+	//Line is a copy of the line that produced the candidate
+	Line HostsFileLine
+
+	//Address is the candidate address
+	Address net.IP
+
+	//Selected is true if the resolver would return this address
+	Selected bool
+
+	//Reason explains why the candidate has been selected or not
+	Reason ResolveReason
+}
+
+// Resolution holds the result of Resolve
+type Resolution struct {
+	//Name is the resolved name
+	Name string
+
+	//Family is the requested address family
+	Family Family
+
+	//Addresses are the selected addresses, in the order the resolver returns them
+	Addresses []net.IP
+
+	//Candidates are every line matching the name, in file order
+	Candidates []ResolveCandidate
+}
+
+// Resolve emulates the files backend of the configured dialect for the given name.
+// names are matched case-insensitively and commented lines are ignored.
+// dialects without multiple matches return the first match for each family,
+// the others return every match. for FamilyAny, IPv6 addresses are returned first.
+// every matching line is returned as a candidate, with the reason it won or lost
+func (h *HostsFile) Resolve(name string, family Family) Resolution {
+	res := Resolution{
+		Name:       name,
+		Family:     family,
+		Addresses:  make([]net.IP, 0),
+		Candidates: make([]ResolveCandidate, 0),
+	}
+
+	multi := h.dialect().MultipleMatches
+	sel := SelectHostname(name)
+
+	// keep track of the first match for each family
+	found := map[bool]bool{}
+
+	for idx, hfl := range h.HostsFileLines {
+		if hfl.Type != LineTypeAddress || !sel(idx, &hfl) {
+			continue
+		}
+
+		c := ResolveCandidate{
+			Row:     idx,
+			Line:    hfl,
+			Address: hfl.Address,
+		}
+
+		isIPv4 := hfl.Address.To4() != nil
+
+		switch {
+		case hfl.IsCommented:
+			c.Reason = ReasonCommented
+		case !family.matches(hfl.Address):
+			c.Reason = ReasonWrongFamily
+		case containsIP(res.Addresses, hfl.Address):
+			c.Reason = ReasonDuplicate
+		case found[isIPv4] && !multi:
+			c.Reason = ReasonShadowed
+		case found[isIPv4]:
+			c.Selected = true
+			c.Reason = ReasonMultipleMatches
+		default:
+			c.Selected = true
+			c.Reason = ReasonFirstMatch
+		}
+
+		if c.Selected {
+			found[isIPv4] = true
+			res.Addresses = append(res.Addresses, hfl.Address)
+		}
+
+		res.Candidates = append(res.Candidates, c)
+	}
+
+	// IPv6 addresses first, file order is kept within the same family
+	sort.SliceStable(res.Addresses, func(i, j int) bool {
+		return res.Addresses[i].To4() == nil && res.Addresses[j].To4() != nil
+	})
+
+	return res
+}
+
+// containsIP reports whether ips contains ip
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, i := range ips {
+		if net.IP.Equal(i, ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,62 @@
+package libhosty
+
+import (
+	"testing"
+)
+
+const resolveHostsFile = `10.0.0.1	foo.local
+# 10.0.0.9	foo.local
+fd00::1	FOO.local
+10.0.0.2	bar.local foo.local
+10.0.0.1	foo.local`
+
+func TestResolveFirstMatch(t *testing.T) {
+	h, err := InitFromString(resolveHostsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Dialect = DialectGlibc
+
+	res := h.Resolve("Foo.Local", FamilyAny)
+
+	if len(res.Addresses) != 2 || res.Addresses[0].String() != "fd00::1" || res.Addresses[1].String() != "10.0.0.1" {
+		t.Fatalf("unexpected addresses: %v", res.Addresses)
+	}
+
+	wants := []ResolveReason{ReasonFirstMatch, ReasonCommented, ReasonFirstMatch, ReasonShadowed, ReasonDuplicate}
+	if len(res.Candidates) != len(wants) {
+		t.Fatalf("wants %d candidates got %d", len(wants), len(res.Candidates))
+	}
+
+	for idx, w := range wants {
+		if res.Candidates[idx].Reason != w {
+			t.Fatalf("candidate %d: wants %s got %s", idx, w, res.Candidates[idx].Reason)
+		}
+
+		if res.Candidates[idx].Row != idx {
+			t.Fatalf("candidate %d: wants row %d got %d", idx, idx, res.Candidates[idx].Row)
+		}
+	}
+}
+
+func TestResolveMultipleMatches(t *testing.T) {
+	h, err := InitFromString(resolveHostsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Dialect = DialectMusl
+
+	res := h.Resolve("foo.local", FamilyIPv4)
+
+	if len(res.Addresses) != 2 || res.Addresses[0].String() != "10.0.0.1" || res.Addresses[1].String() != "10.0.0.2" {
+		t.Fatalf("unexpected addresses: %v", res.Addresses)
+	}
+
+	if res.Candidates[2].Reason != ReasonWrongFamily || res.Candidates[3].Reason != ReasonMultipleMatches {
+		t.Fatalf("unexpected candidates: %v", res.Candidates)
+	}
+
+	if res := h.Resolve("missing.local", FamilyAny); len(res.Addresses) != 0 || len(res.Candidates) != 0 {
+		t.Fatalf("unexpected resolution: %v", res)
+	}
+}
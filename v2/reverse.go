@@ -0,0 +1,89 @@
+package libhosty
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// LookupByAddress returns the hostnames mapped to the given IP as String, as the resolver would
+// return them for a reverse lookup: the first hostname of the first matching line is the canonical name,
+// the others are aliases. dialects with multiple matches add hostnames from every other matching line.
+// commented lines are ignored.
+// error is not nil if the address cannot be parsed or is not found
+func (h *HostsFile) LookupByAddress(address string) (string, []string, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return "", nil, ErrCannotParseIPAddress(address)
+	}
+
+	multi := h.dialect().MultipleMatches
+
+	canonical := ""
+	aliases := make([]string, 0)
+	seen := map[string]bool{}
+
+	for _, hfl := range h.HostsFileLines {
+		if hfl.Type != LineTypeAddress || hfl.IsCommented || !net.IP.Equal(ip, hfl.Address) {
+			continue
+		}
+
+		// first match only
+		if canonical != "" && !multi {
+			break
+		}
+
+		for _, hn := range hfl.Hostnames {
+			if seen[normalizeHostname(hn)] {
+				continue
+			}
+			seen[normalizeHostname(hn)] = true
+
+			if canonical == "" {
+				canonical = hn
+				continue
+			}
+
+			aliases = append(aliases, hn)
+		}
+	}
+
+	if canonical == "" {
+		return "", nil, ErrAddressNotFound
+	}
+
+	return canonical, aliases, nil
+}
+
+// ReverseName returns the PTR-style name for the given IP,
+// in the in-addr.arpa domain for IPv4 and in the ip6.arpa domain for IPv6.
+// returns an empty string for invalid IPs
+func ReverseName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return strconv.Itoa(int(ip4[3])) + "." +
+			strconv.Itoa(int(ip4[2])) + "." +
+			strconv.Itoa(int(ip4[1])) + "." +
+			strconv.Itoa(int(ip4[0])) + ".in-addr.arpa."
+	}
+
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return ""
+	}
+
+	const hexDigits = "0123456789abcdef"
+
+	var b strings.Builder
+
+	// one label for each nibble, least significant first
+	for idx := len(ip16) - 1; idx >= 0; idx-- {
+		b.WriteByte(hexDigits[ip16[idx]&0x0f])
+		b.WriteByte('.')
+		b.WriteByte(hexDigits[ip16[idx]>>4])
+		b.WriteByte('.')
+	}
+
+	b.WriteString("ip6.arpa.")
+
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package libhosty
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestLookupByAddress(t *testing.T) {
+	h, err := InitFromString("# 10.0.0.1 old.local\n10.0.0.1 web.local www.local\n10.0.0.2 db.local\n10.0.0.1 api.local WEB.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Dialect = DialectGlibc
+
+	canonical, aliases, err := h.LookupByAddress("10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if canonical != "web.local" || !slices.Equal(aliases, []string{"www.local"}) {
+		t.Fatalf("unexpected result: %s %q", canonical, aliases)
+	}
+
+	h.Dialect = DialectMusl
+
+	canonical, aliases, err = h.LookupByAddress("10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if canonical != "web.local" || !slices.Equal(aliases, []string{"www.local", "api.local"}) {
+		t.Fatalf("unexpected result: %s %q", canonical, aliases)
+	}
+
+	if _, _, err := h.LookupByAddress("10.0.0.3"); err != ErrAddressNotFound {
+		t.Fatalf("wants %v got %v", ErrAddressNotFound, err)
+	}
+
+	if _, _, err := h.LookupByAddress("fa.ke.i.p"); err == nil {
+		t.Fatal("should fail with invalid address")
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	cases := []struct {
+		ip   string
+		want string
+	}{
+		{"10.0.0.5", "5.0.0.10.in-addr.arpa."},
+		{"2001:db8::567:89ab", "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."},
+	}
+
+	for _, c := range cases {
+		if got := ReverseName(net.ParseIP(c.ip)); got != c.want {
+			t.Fatalf("wants %q got %q", c.want, got)
+		}
+	}
+
+	if got := ReverseName(nil); got != "" {
+		t.Fatalf("wants empty string got %q", got)
+	}
+}
@@ -0,0 +1,161 @@
+package libhosty
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinks defines how many symlinks are followed when resolving a name inside a root
+const maxSymlinks = 255
+
+// RootFS is an FS resolving every name relative to Root, the way chroot(2) would.
+// symlinks are resolved inside Root, so absolute and .. targets cannot escape it.
+// windows names (C:\Windows\...) are resolved as Root/Windows/...
+type RootFS struct {
+	//Root is the root filesystem path, like a mounted image
+	Root string
+}
+
+// ReadFile reads the named file inside Root
+func (r RootFS) ReadFile(name string) ([]byte, error) {
+	p, err := r.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(p)
+}
+
+// WriteFile writes the named file inside Root, the same way OSFS does
+func (r RootFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	p, err := r.Resolve(name)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(p, data, fileAttrs{perm: perm})
+}
+
+// writeFileAttrs writes the named file inside Root, using attrs if the file is created
+func (r RootFS) writeFileAttrs(name string, data []byte, attrs fileAttrs) error {
+	p, err := r.Resolve(name)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(p, data, attrs)
+}
+
+// appendFile appends data to the named file inside Root, creating it with perm if necessary
+func (r RootFS) appendFile(name string, data []byte, perm fs.FileMode) error {
+	p, err := r.Resolve(name)
+	if err != nil {
+		return err
+	}
+
+	return appendOSFile(p, data, perm)
+}
+
+// lockFile acquires an advisory lock on the named file inside Root
+func (r RootFS) lockFile(ctx context.Context, name string, exclusive bool) (func(), error) {
+	p, err := r.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return lockFile(ctx, p, exclusive)
+}
+
+// Resolve returns the path of name inside Root, following symlinks without leaving Root.
+// missing components are joined as they are.
+// error is not nil if something goes wrong
+func (r RootFS) Resolve(name string) (string, error) {
+	root := filepath.Clean(r.Root)
+
+	// resolved is a slash-separated path, relative to root
+	resolved := ""
+	remaining := rootRelativeName(name)
+	links := 0
+
+	for remaining != "" {
+		var part string
+		part, remaining, _ = strings.Cut(remaining, "/")
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			// never go above root
+			if resolved = path.Dir(resolved); resolved == "." {
+				resolved = ""
+			}
+			continue
+		}
+
+		next := path.Join(resolved, part)
+
+		fi, err := os.Lstat(filepath.Join(root, filepath.FromSlash(next)))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				resolved = next
+				continue
+			}
+
+			return "", err
+		}
+
+		if fi.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", &fs.PathError{Op: "resolve", Path: name, Err: ErrTooManySymlinks}
+		}
+
+		target, err := os.Readlink(filepath.Join(root, filepath.FromSlash(next)))
+		if err != nil {
+			return "", err
+		}
+
+		target = filepath.ToSlash(target)
+
+		// absolute targets are relative to root
+		if path.IsAbs(target) {
+			resolved = ""
+		}
+
+		remaining = target + "/" + remaining
+	}
+
+	return filepath.Join(root, filepath.FromSlash(resolved)), nil
+}
+
+// rootRelativeName converts name to a slash-separated name, stripping windows volume names
+func rootRelativeName(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+
+	if len(name) >= 2 && name[1] == ':' {
+		name = name[2:]
+	}
+
+	return name
+}
+
+// WithRoot resolves every path relative to the given root filesystem, see RootFS
+func WithRoot(root string) Option {
+	return WithFS(RootFS{Root: root})
+}
+
+// GetRootedHostsFilePath returns the path of the hostsfile of the running system inside the given root,
+// following symlinks without leaving root.
+// error is not nil if something goes wrong
+func GetRootedHostsFilePath(root string) (string, error) {
+	return RootFS{Root: root}.Resolve(GetOSHostsFilePath())
+}
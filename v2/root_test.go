@@ -0,0 +1,97 @@
+package libhosty
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestRoot(t *testing.T) string {
+	root := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "etc", "hosts.real"), []byte("127.0.0.1 localhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+func TestRootFSResolve(t *testing.T) {
+	root := newTestRoot(t)
+
+	// absolute symlinks are resolved inside root
+	if err := os.Symlink("/etc/hosts.real", filepath.Join(root, "etc", "hosts")); err != nil {
+		t.Fatal(err)
+	}
+
+	// .. cannot escape root
+	if err := os.Symlink("../../../../../etc/hosts.real", filepath.Join(root, "etc", "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"/etc/hosts":                            filepath.Join(root, "etc", "hosts.real"),
+		"/etc/escape":                           filepath.Join(root, "etc", "hosts.real"),
+		"/../../etc/missing":                    filepath.Join(root, "etc", "missing"),
+		"/.cfg/x/../hosts":                      filepath.Join(root, ".cfg", "hosts"),
+		`C:\Windows\System32\drivers\etc\hosts`: filepath.Join(root, "Windows", "System32", "drivers", "etc", "hosts"),
+	}
+
+	for name, want := range cases {
+		got, err := RootFS{Root: root}.Resolve(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != want {
+			t.Fatalf("%s: wants %q got %q", name, want, got)
+		}
+	}
+
+	// symlink loops are detected
+	if err := os.Symlink("loop", filepath.Join(root, "etc", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := (RootFS{Root: root}).Resolve("/etc/loop"); err == nil {
+		t.Fatal("should fail with symlink loop")
+	}
+}
+
+func TestWithRoot(t *testing.T) {
+	root := newTestRoot(t)
+
+	if err := os.Symlink("hosts.real", filepath.Join(root, "etc", "hosts")); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := Open(context.Background(), "/etc/hosts", WithRoot(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.AddHostsFileLine("10.0.0.1", "image.local", "")
+
+	if err := h.WriteHostsFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "etc", "hosts.real"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hfl, err := ParseHostsFileFromString(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hfl) != 3 || hfl[2].Hostnames[0] != "image.local" {
+		t.Fatalf("unexpected lines: %v", hfl)
+	}
+}
@@ -0,0 +1,356 @@
+package libhosty
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"unicode"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// defaultMaxWhitespace is the longest whitespace run expected inside an entry
+	defaultMaxWhitespace = 16
+
+	// defaultMaxEmptyLines is the longest empty line run expected before an entry
+	defaultMaxEmptyLines = 10
+
+	// defaultMaxLineLength is the longest entry expected, longer ones scroll off screen
+	defaultMaxLineLength = 256
+)
+
+// defaultSensitiveDomains are well-known domains commonly hijacked via the hosts file
+var defaultSensitiveDomains = []string{
+	"google.com",
+	"googleapis.com",
+	"gmail.com",
+	"youtube.com",
+	"microsoft.com",
+	"windowsupdate.com",
+	"live.com",
+	"office.com",
+	"apple.com",
+	"icloud.com",
+	"facebook.com",
+	"instagram.com",
+	"twitter.com",
+	"x.com",
+	"amazon.com",
+	"paypal.com",
+	"github.com",
+	"dropbox.com",
+	"yahoo.com",
+	"linkedin.com",
+}
+
+// Severity define a safe type for the severity of a finding
+type Severity int
+
+const (
+	//SeverityInfo the finding is informational
+	SeverityInfo Severity = iota
+
+	//SeverityLow the finding is unexpected but likely harmless
+	SeverityLow
+
+	//SeverityMedium the finding can hide malicious entries
+	SeverityMedium
+
+	//SeverityHigh the finding is likely malicious
+	SeverityHigh
+
+	//SeverityCritical the finding redirects a configured sensitive domain
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "severity-info"
+	case SeverityLow:
+		return "severity-low"
+	case SeverityMedium:
+		return "severity-medium"
+	case SeverityHigh:
+		return "severity-high"
+	case SeverityCritical:
+		return "severity-critical"
+	default:
+		return "severity-unknown"
+	}
+}
+
+// FindingKind define a safe type for the kind of a finding
+type FindingKind int
+
+const (
+	//FindingSensitiveRedirect a sensitive domain is mapped to a public address
+	FindingSensitiveRedirect FindingKind = iota
+
+	//FindingHiddenEntry an entry is hidden by whitespace, empty lines or its length
+	FindingHiddenEntry
+
+	//FindingOddCharacters a line contains control, invisible or non-ASCII characters
+	FindingOddCharacters
+
+	//FindingUnmanagedLine an entry is outside the managed sections
+	FindingUnmanagedLine
+)
+
+func (k FindingKind) String() string {
+	switch k {
+	case FindingSensitiveRedirect:
+		return "finding-sensitive-redirect"
+	case FindingHiddenEntry:
+		return "finding-hidden-entry"
+	case FindingOddCharacters:
+		return "finding-odd-characters"
+	case FindingUnmanagedLine:
+		return "finding-unmanaged-line"
+	default:
+		return "finding-unknown"
+	}
+}
+
+// Finding holds a suspicious line reported by Audit
+type Finding struct {
+	//Severity is how suspicious the line is
+	Severity Severity
+
+	//Kind is what is suspicious about the line
+	Kind FindingKind
+
+	//Row is the row of the line
+	Row int
+
+	//Line is a copy of the line
+	Line HostsFileLine
+
+	//Hostname is the suspicious hostname, if any
+	Hostname string
+
+	//Message describes the finding
+	Message string
+}
+
+// AuditConfig holds the Audit settings, zero fields use the defaults
+type AuditConfig struct {
+	//SensitiveDomains are domains, and their subdomains, that must not be mapped to public addresses.
+	//they are reported with SeverityCritical
+	SensitiveDomains []string
+
+	//IgnoreDefaultDomains skips the well-known domains checked by default
+	IgnoreDefaultDomains bool
+
+	//ManagedSections are the names of the sections delimited by "# BEGIN name" and "# END name"
+	//that are expected to hold every entry. empty disables the check
+	ManagedSections []string
+
+	//MaxWhitespace is the longest whitespace run expected inside an entry
+	MaxWhitespace int
+
+	//MaxEmptyLines is the longest empty line run expected before an entry
+	MaxEmptyLines int
+
+	//MaxLineLength is the longest entry expected
+	MaxLineLength int
+}
+
+// Audit looks for suspicious entries in the hosts file with the default settings.
+// findings are sorted by severity, most severe first, then by row
+func Audit(h *HostsFile) []Finding {
+	return AuditWithConfig(h, AuditConfig{})
+}
+
+// AuditWithConfig looks for suspicious entries in the hosts file.
+// findings are sorted by severity, most severe first, then by row
+func AuditWithConfig(h *HostsFile, cfg AuditConfig) []Finding {
+	h.Lock()
+	lines := cloneHostsFileLines(h.HostsFileLines)
+	h.Unlock()
+
+	cfg = cfg.withDefaults()
+
+	sections := findSections(lines, cfg.ManagedSections)
+	loopback := DefaultPolicy()
+
+	res := make([]Finding, 0)
+	emptyLines := 0
+
+	for row, hfl := range lines {
+		// leading and trailing whitespace are trimmed from Raw, not from the file
+		raw := untrimmedRaw(hfl)
+
+		if r, ok := oddRune(raw); ok {
+			res = append(res, auditFinding(SeverityHigh, FindingOddCharacters, row, hfl, "",
+				fmt.Sprintf("line contains the invisible or control character %U", r)))
+		}
+
+		if hfl.Type == LineTypeEmpty {
+			emptyLines++
+			continue
+		}
+
+		if hfl.Type != LineTypeAddress || hfl.IsCommented {
+			emptyLines = 0
+			continue
+		}
+
+		if emptyLines > cfg.MaxEmptyLines {
+			res = append(res, auditFinding(SeverityMedium, FindingHiddenEntry, row, hfl, "",
+				fmt.Sprintf("entry follows %d empty lines", emptyLines)))
+		}
+		emptyLines = 0
+
+		if n := longestWhitespace(raw); n > cfg.MaxWhitespace {
+			res = append(res, auditFinding(SeverityMedium, FindingHiddenEntry, row, hfl, "",
+				fmt.Sprintf("entry contains a run of %d whitespace characters", n)))
+		}
+
+		if len(raw) > cfg.MaxLineLength {
+			res = append(res, auditFinding(SeverityMedium, FindingHiddenEntry, row, hfl, "",
+				fmt.Sprintf("entry is %d characters long", len(raw))))
+		}
+
+		for _, hn := range hfl.Hostnames {
+			if !isASCII(hn) {
+				res = append(res, auditFinding(SeverityMedium, FindingOddCharacters, row, hfl, hn,
+					fmt.Sprintf("hostname %s contains non-ASCII characters", hn)))
+			}
+
+			if sev, domain, ok := cfg.sensitiveDomain(hn); ok && !isLocalAddress(hfl.Address) {
+				res = append(res, auditFinding(sev, FindingSensitiveRedirect, row, hfl, hn,
+					fmt.Sprintf("%s, under %s, is mapped to %s", hn, domain, hfl.Address)))
+			}
+		}
+
+		if len(cfg.ManagedSections) > 0 && !hfl.Address.IsLoopback() && !loopback.isProtected(hfl) {
+			if _, ok := sections.contains(row); !ok {
+				res = append(res, auditFinding(SeverityLow, FindingUnmanagedLine, row, hfl, "",
+					"entry is outside the managed sections"))
+			}
+		}
+	}
+
+	slices.SortStableFunc(res, func(a, b Finding) int {
+		if a.Severity != b.Severity {
+			return int(b.Severity) - int(a.Severity)
+		}
+
+		return a.Row - b.Row
+	})
+
+	return res
+}
+
+// withDefaults returns cfg with the zero limits set to the defaults
+func (cfg AuditConfig) withDefaults() AuditConfig {
+	if cfg.MaxWhitespace <= 0 {
+		cfg.MaxWhitespace = defaultMaxWhitespace
+	}
+
+	if cfg.MaxEmptyLines <= 0 {
+		cfg.MaxEmptyLines = defaultMaxEmptyLines
+	}
+
+	if cfg.MaxLineLength <= 0 {
+		cfg.MaxLineLength = defaultMaxLineLength
+	}
+
+	return cfg
+}
+
+// sensitiveDomain returns the severity and the sensitive domain hostname belongs to
+func (cfg AuditConfig) sensitiveDomain(hostname string) (Severity, string, bool) {
+	for _, domain := range cfg.SensitiveDomains {
+		if matchDomain(hostname, normalizeHostname(domain)) {
+			return SeverityCritical, domain, true
+		}
+	}
+
+	if cfg.IgnoreDefaultDomains {
+		return SeverityInfo, "", false
+	}
+
+	for _, domain := range defaultSensitiveDomains {
+		if matchDomain(hostname, domain) {
+			return SeverityHigh, domain, true
+		}
+	}
+
+	return SeverityInfo, "", false
+}
+
+// auditFinding returns a Finding
+func auditFinding(sev Severity, kind FindingKind, row int, hfl HostsFileLine, hostname, message string) Finding {
+	return Finding{
+		Severity: sev,
+		Kind:     kind,
+		Row:      row,
+		Line:     hfl,
+		Hostname: hostname,
+		Message:  message,
+	}
+}
+
+// isLocalAddress reports whether ip cannot reach the internet,
+// mapping a domain to it blocks the domain instead of hijacking it
+func isLocalAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast()
+}
+
+// oddRune returns the first control or invisible formatting character of s, tabs excluded
+func oddRune(s string) (rune, bool) {
+	for _, r := range s {
+		if r == '\t' {
+			continue
+		}
+
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) || r == unicode.ReplacementChar {
+			return r, true
+		}
+	}
+
+	return 0, false
+}
+
+// untrimmedRaw returns the line as parsed, whitespace included,
+// or Raw if the line has been edited since
+func untrimmedRaw(hfl HostsFileLine) string {
+	if hfl.untrimmed != "" && strings.TrimSpace(hfl.untrimmed) == hfl.Raw {
+		return hfl.untrimmed
+	}
+
+	return hfl.Raw
+}
+
+// longestWhitespace returns the length of the longest whitespace run of s
+func longestWhitespace(s string) int {
+	longest, cur := 0, 0
+
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			cur = 0
+			continue
+		}
+
+		cur++
+		if cur > longest {
+			longest = cur
+		}
+	}
+
+	return longest
+}
+
+// isASCII reports whether s only contains ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] > unicode.MaxASCII {
+			return false
+		}
+	}
+
+	return true
+}
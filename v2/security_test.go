@@ -0,0 +1,112 @@
+package libhosty
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	content := strings.Join([]string{
+		"127.0.0.1 localhost",
+		"0.0.0.0 ads.google.com",
+		"203.0.113.7 www.paypal.com",
+		"10.0.0.1 intranet",
+		"203.0.113.8 login.sso.example.com",
+		"# 203.0.113.9 www.github.com",
+		"203.0.113.10 evil" + strings.Repeat(" ", 40) + "www.apple.com",
+		"203.0.113.11 g\u043e\u043egle.com",
+		"203.0.113.12 upd\u200bate.local",
+	}, "\n")
+
+	h, err := InitFromString(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings := AuditWithConfig(h, AuditConfig{SensitiveDomains: []string{"sso.example.com"}})
+
+	type expected struct {
+		sev  Severity
+		kind FindingKind
+		row  int
+	}
+
+	want := []expected{
+		{SeverityCritical, FindingSensitiveRedirect, 4},
+		{SeverityHigh, FindingSensitiveRedirect, 2},
+		{SeverityHigh, FindingSensitiveRedirect, 6},
+		{SeverityHigh, FindingOddCharacters, 8},
+		{SeverityMedium, FindingHiddenEntry, 6},
+		{SeverityMedium, FindingOddCharacters, 7},
+		{SeverityMedium, FindingOddCharacters, 8},
+	}
+
+	if len(findings) != len(want) {
+		t.Fatalf("expected %d findings, got %v", len(want), findings)
+	}
+
+	for i, w := range want {
+		f := findings[i]
+		if f.Severity != w.sev || f.Kind != w.kind || f.Row != w.row {
+			t.Fatalf("finding %d: expected %v, got %v", i, w, f)
+		}
+	}
+}
+
+func TestAuditHiddenByEmptyLines(t *testing.T) {
+	h, err := InitFromString("127.0.0.1 localhost" + strings.Repeat("\n", 30) + "10.0.0.1 db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings := Audit(h)
+	if len(findings) != 1 || findings[0].Kind != FindingHiddenEntry || findings[0].Row != 30 {
+		t.Fatalf("unexpected findings %v", findings)
+	}
+}
+
+func TestAuditHiddenByLeadingWhitespace(t *testing.T) {
+	h, err := InitFromString("127.0.0.1 localhost\n" + strings.Repeat(" ", 300) + "1.2.3.4 google.com\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hidden := 0
+	for _, f := range Audit(h) {
+		if f.Kind == FindingHiddenEntry && f.Row == 1 {
+			hidden++
+		}
+	}
+
+	// the whitespace run and the line length are both reported
+	if hidden != 2 {
+		t.Fatalf("expected 2 hidden entry findings, got %v", Audit(h))
+	}
+
+	// once edited, the line is rendered without the whitespace
+	if err := h.SetAnnotation(1, "owner", "me"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range Audit(h) {
+		if f.Kind == FindingHiddenEntry {
+			t.Fatalf("unexpected finding %v", f)
+		}
+	}
+}
+
+func TestAuditManagedSections(t *testing.T) {
+	h, err := InitFromString("127.0.0.1 localhost\n::1 ip6-localhost\n# BEGIN corp\n10.1.0.1 git.corp\n# END corp\n10.0.0.1 stray\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings := AuditWithConfig(h, AuditConfig{ManagedSections: []string{"corp"}})
+	if len(findings) != 1 || findings[0].Kind != FindingUnmanagedLine || findings[0].Row != 5 {
+		t.Fatalf("unexpected findings %v", findings)
+	}
+
+	if findings := Audit(h); len(findings) != 0 {
+		t.Fatalf("expected no findings, got %v", findings)
+	}
+}
@@ -50,3 +50,8 @@ const dockerDesktopTemplate = `# Added by Docker Desktop
 127.0.0.1 kubernetes.docker.internal
 # End of section
 `
+
+// androidHostsTemplate defines default android hosts file
+const androidHostsTemplate = `127.0.0.1       localhost
+::1             ip6-localhost
+`
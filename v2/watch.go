@@ -0,0 +1,198 @@
+package libhosty
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+var (
+	// watchDebounce defines how long the file must be quiet before it is parsed again
+	watchDebounce = 100 * time.Millisecond
+
+	// watchPollInterval defines how often the file is checked when notifications are not available
+	watchPollInterval = time.Second
+
+	// watcherFactory returns the notification based fileWatcher of a path
+	watcherFactory = newFileWatcher
+)
+
+// WatchEvent holds a change of the watched hosts file, or an error
+type WatchEvent struct {
+	Change
+
+	//Err is not nil if the hosts file cannot be watched or parsed,
+	//Change is empty in that case
+	Err error
+}
+
+// fileWatcher notifies when a file may have changed
+type fileWatcher interface {
+	// changes receives a value when the file may have changed
+	changes() <-chan struct{}
+
+	// errors receives errors preventing the file from being watched
+	errors() <-chan error
+
+	// close stops the watcher
+	close() error
+}
+
+// Watch watches the hosts file at path and emits the entry level changes,
+// computed by parsing the file and diffing it against the previous parse.
+// options configure parsing, like WithDialect, WithLineLimits and WithStrict,
+// the file is always read from the operating system filesystem.
+// writes are debounced, so a burst of writes emits the changes once.
+// inotify is used on Linux, other platforms poll the file,
+// as does Linux if inotify cannot be set up or fails while watching.
+// a missing file is handled as an empty one.
+// the returned channel is closed when ctx is done.
+// error is not nil if something goes wrong
+func Watch(ctx context.Context, path string, opts ...Option) (<-chan WatchEvent, error) {
+	cfg := New(opts...).parserConfig()
+
+	prev, err := parseWatchedFile(path, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := watcherFactory(path)
+	if err != nil {
+		w = newPollWatcher(path, watchPollInterval)
+	}
+
+	events := make(chan WatchEvent)
+
+	go func() {
+		defer close(events)
+		defer func() { w.close() }()
+
+		send := func(ev WatchEvent) bool {
+			select {
+			case events <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var debounce <-chan time.Time
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.changes():
+				debounce = time.After(watchDebounce)
+			case <-w.errors():
+				// notifications failed, poll the file and parse it again in case a change was missed
+				w.close()
+				w = newPollWatcher(path, watchPollInterval)
+				debounce = time.After(watchDebounce)
+			case <-debounce:
+				debounce = nil
+
+				cur, err := parseWatchedFile(path, cfg)
+				if err != nil {
+					if !send(WatchEvent{Err: err}) {
+						return
+					}
+					continue
+				}
+
+				for _, c := range DiffHostsFileLines(prev, cur) {
+					if !send(WatchEvent{Change: c}) {
+						return
+					}
+				}
+
+				prev = cur
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// parseWatchedFile parses the hosts file at path with cfg, a missing file has no lines
+func parseWatchedFile(path string, cfg parserConfig) ([]HostsFileLine, error) {
+	byteData, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []HostsFileLine{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parser(byteData, cfg)
+}
+
+// pollWatcher is a fileWatcher checking the file size, modification time and identity
+type pollWatcher struct {
+	ch   chan struct{}
+	errs chan error
+	done chan struct{}
+}
+
+// newPollWatcher returns a fileWatcher checking path every interval
+func newPollWatcher(path string, interval time.Duration) *pollWatcher {
+	w := &pollWatcher{
+		ch:   make(chan struct{}, 1),
+		errs: make(chan error),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		last, _ := os.Stat(path)
+
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+			}
+
+			cur, _ := os.Stat(path)
+			if sameFileState(last, cur) {
+				continue
+			}
+
+			last = cur
+
+			select {
+			case w.ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return w
+}
+
+func (w *pollWatcher) changes() <-chan struct{} {
+	return w.ch
+}
+
+func (w *pollWatcher) errors() <-chan error {
+	return w.errs
+}
+
+func (w *pollWatcher) close() error {
+	close(w.done)
+	return nil
+}
+
+// sameFileState reports whether a and b describe the same, unchanged, file.
+// nil means the file does not exist
+func sameFileState(a, b fs.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return os.SameFile(a, b) && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
@@ -0,0 +1,149 @@
+//go:build linux
+
+package libhosty
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// inotifyDirMask defines the directory events that may change the watched file,
+	// atomic writes rename a new file over it
+	inotifyDirMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+		syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_ATTRIB
+
+	// inotifyFileMask defines the events of the watched file itself,
+	// needed for bind mounts whose writes are not reported to the directory
+	inotifyFileMask = syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_ATTRIB |
+		syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+)
+
+// inotifyWatcher is a fileWatcher based on inotify(7)
+type inotifyWatcher struct {
+	f    *os.File
+	fd   int
+	path string
+
+	// watched file names, by directory watch descriptor
+	dirs map[int32]map[string]bool
+
+	ch   chan struct{}
+	errs chan error
+}
+
+// newFileWatcher returns an inotify based fileWatcher for path.
+// the directories of path and of its symlink target are watched as well,
+// so the file can be replaced or created
+func newFileWatcher(path string) (fileWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	// non-blocking descriptors are handled by the runtime poller, so close unblocks reads
+	w := &inotifyWatcher{
+		f:    os.NewFile(uintptr(fd), "inotify"),
+		fd:   fd,
+		path: path,
+		dirs: make(map[int32]map[string]bool),
+		ch:   make(chan struct{}, 1),
+		errs: make(chan error, 1),
+	}
+
+	names := []string{path}
+	if target, err := filepath.EvalSymlinks(path); err == nil && target != path {
+		names = append(names, target)
+	}
+
+	for _, name := range names {
+		wd, err := syscall.InotifyAddWatch(fd, filepath.Dir(name), inotifyDirMask)
+		if err != nil {
+			w.f.Close()
+			return nil, os.NewSyscallError("inotify_add_watch", err)
+		}
+
+		if w.dirs[int32(wd)] == nil {
+			w.dirs[int32(wd)] = make(map[string]bool)
+		}
+		w.dirs[int32(wd)][filepath.Base(name)] = true
+	}
+
+	w.watchFile()
+
+	go w.run()
+
+	return w, nil
+}
+
+// watchFile watches the current file behind path, it may be missing.
+// the raw descriptor is used since File.Fd would make reads blocking
+func (w *inotifyWatcher) watchFile() {
+	syscall.InotifyAddWatch(w.fd, w.path, inotifyFileMask)
+}
+
+// run reads inotify events until the watcher is closed
+func (w *inotifyWatcher) run() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+
+	for {
+		n, err := w.f.Read(buf)
+		if errors.Is(err, os.ErrClosed) {
+			return
+		}
+		if err != nil {
+			w.errs <- err
+			return
+		}
+
+		changed := false
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			offset = nameStart + int(ev.Len)
+
+			names, isDir := w.dirs[ev.Wd]
+			if !isDir {
+				// events of the file itself
+				changed = changed || ev.Mask&syscall.IN_IGNORED == 0
+				continue
+			}
+
+			// names are NUL padded
+			name := string(buf[nameStart:offset])
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+
+			changed = changed || names[name]
+		}
+
+		if !changed {
+			continue
+		}
+
+		// the file may have been replaced, watch the new one
+		w.watchFile()
+
+		select {
+		case w.ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *inotifyWatcher) changes() <-chan struct{} {
+	return w.ch
+}
+
+func (w *inotifyWatcher) errors() <-chan error {
+	return w.errs
+}
+
+func (w *inotifyWatcher) close() error {
+	return w.f.Close()
+}
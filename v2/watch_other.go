@@ -0,0 +1,10 @@
+//go:build !linux
+
+package libhosty
+
+import "errors"
+
+// newFileWatcher is not available without inotify, Watch falls back to polling
+func newFileWatcher(path string) (fileWatcher, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,167 @@
+package libhosty
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func expectWatchEvent(t *testing.T, events <-chan WatchEvent, kind ChangeKind, hostname string) {
+	t.Helper()
+
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("events channel closed")
+		}
+
+		if ev.Err != nil {
+			t.Fatal(ev.Err)
+		}
+
+		if ev.Kind != kind || ev.Hostname != hostname {
+			t.Fatalf("expected %s %s, got %s %s", kind, hostname, ev.Kind, ev.Hostname)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for %s %s", kind, hostname)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "hosts")
+
+	if err := os.WriteFile(p, []byte("127.0.0.1 localhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := Watch(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// in place write
+	if err := os.WriteFile(p, []byte("127.0.0.1 localhost\n10.0.0.1 db\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expectWatchEvent(t, events, ChangeAdded, "db")
+
+	// atomic replace
+	hf, err := InitFromCustomPath(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hf.DisableHostname("db"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hf.WriteHostsFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	expectWatchEvent(t, events, ChangeCommented, "db")
+
+	cancel()
+
+	for range events {
+	}
+}
+
+func TestWatchPolling(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "hosts")
+
+	w := newPollWatcher(p, 10*time.Millisecond)
+	defer w.close()
+
+	// wait for the first check of the missing file
+	time.Sleep(50 * time.Millisecond)
+
+	if err := os.WriteFile(p, []byte("127.0.0.1 localhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-w.changes():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the file creation")
+	}
+}
+
+func TestWatchOptions(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "hosts")
+
+	if err := os.WriteFile(p, []byte("127.0.0.1 localhost\nnot.an.address db\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the invalid line is rejected in strict mode only
+	if _, err := Watch(ctx, p, WithStrict(true)); err == nil {
+		t.Fatal("expected the strict parser to fail")
+	}
+
+	events, err := Watch(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+
+	for range events {
+	}
+}
+
+// failingWatcher is a fileWatcher failing as soon as it is created
+type failingWatcher struct {
+	errs chan error
+}
+
+func (w *failingWatcher) changes() <-chan struct{} { return nil }
+func (w *failingWatcher) errors() <-chan error     { return w.errs }
+func (w *failingWatcher) close() error             { return nil }
+
+func TestWatchFallback(t *testing.T) {
+	defer func(factory func(string) (fileWatcher, error), interval time.Duration) {
+		watcherFactory, watchPollInterval = factory, interval
+	}(watcherFactory, watchPollInterval)
+
+	watcherFactory = func(string) (fileWatcher, error) {
+		w := &failingWatcher{errs: make(chan error, 1)}
+		w.errs <- errors.New("read failed")
+		return w, nil
+	}
+	watchPollInterval = 10 * time.Millisecond
+
+	p := filepath.Join(t.TempDir(), "hosts")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := Watch(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for the poller to check the missing file
+	time.Sleep(100 * time.Millisecond)
+
+	if err := os.WriteFile(p, []byte("10.0.0.1 db\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expectWatchEvent(t, events, ChangeAdded, "db")
+
+	cancel()
+
+	for range events {
+	}
+}
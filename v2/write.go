@@ -1,7 +1,9 @@
 package libhosty
 
 import (
-	"os"
+	"context"
+	"errors"
+	"io/fs"
 )
 
 // WriteHostsFile write hosts file to configured path.
@@ -17,14 +19,60 @@ func (h *HostsFile) WriteHostsFile() error {
 // WriteHostsFileTo write hosts file to the given path.
 // error is not nil if something goes wrong
 func (h *HostsFile) WriteHostsFileTo(path string) error {
+	// lock the directory of the file, if any
+	unlock, err := h.lockFile(context.Background(), path, true)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		defer unlock()
+	}
+
 	// render the file as a byte slice
-	dataBytes := []byte(h.RenderHostsFile())
+	dataBytes := h.renderContent(h.HostsFileLines)
 
 	// write file to disk
-	err := os.WriteFile(path, dataBytes, 0644)
+	err = h.writeFile(path, dataBytes)
 	if err != nil {
 		return err
 	}
 
+	// audit logs and other write hooks
+	if err := h.afterWrite(path, dataBytes); err != nil {
+		return err
+	}
+
+	// the written lines are the new base for Reload
+	if path == h.Path {
+		h.Lock()
+		h.base = cloneHostsFileLines(h.HostsFileLines)
+		h.loaded = dataBytes
+		h.Unlock()
+	}
+
 	return nil
 }
+
+// renderContent returns the content written for lines, with the integrity header if maintained
+func (h *HostsFile) renderContent(lines []HostsFileLine) []byte {
+	data := []byte(h.renderHostsFileLines(lines))
+
+	// refresh the integrity header
+	if h.integrity {
+		data = sealIntegrity(data, h.integrityKey, h.dialect().lineEnding())
+	}
+
+	return data
+}
+
+// writeFile writes data to path with the configured filesystem,
+// using the configured mode and owner if the file is created
+func (h *HostsFile) writeFile(path string, data []byte) error {
+	attrs := h.newFile.withDefaults()
+
+	if w, ok := h.filesystem().(fileAttrsWriter); ok {
+		return w.writeFileAttrs(path, data, attrs)
+	}
+
+	return h.filesystem().WriteFile(path, data, attrs.perm)
+}
@@ -0,0 +1,156 @@
+package libhosty
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// renameFile renames a file, replaced in tests to simulate bind mounts
+var renameFile = os.Rename
+
+// defaultFileMode defines the permissions of created hosts files
+const defaultFileMode fs.FileMode = 0644
+
+// fileAttrs holds the metadata of a hosts file
+type fileAttrs struct {
+	// permissions, including setuid, setgid and sticky bits
+	perm fs.FileMode
+
+	// owner, used only if hasOwner is true
+	uid      int
+	gid      int
+	hasOwner bool
+
+	// extended attributes, by name
+	xattrs map[string][]byte
+}
+
+// withDefaults returns a copy of attrs with the default permissions if none is set
+func (a fileAttrs) withDefaults() fileAttrs {
+	if a.perm == 0 {
+		a.perm = defaultFileMode
+	}
+
+	return a
+}
+
+// fileModeBits returns the permission bits of mode, as accepted by chmod
+func fileModeBits(mode fs.FileMode) fs.FileMode {
+	return mode & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+}
+
+// writeFile writes data to the named file, choosing a strategy that keeps the file in place:
+//   - symlinks are followed, so the link is kept and its target is written
+//   - the target is replaced atomically, with a temporary file renamed over it,
+//     carrying the mode, owner and extended attributes of the existing file
+//   - if the target cannot be replaced, like a bind mount (EBUSY), a file whose
+//     directory is not writable or whose owner or security labels cannot be set,
+//     it is truncated and written in place, which keeps its metadata as well
+//
+// attrs are used for new files
+func writeFile(name string, data []byte, attrs fileAttrs) error {
+	target, err := resolveWriteTarget(name)
+	if err != nil {
+		return err
+	}
+
+	existing, err := statFileAttrs(target)
+	if err == nil {
+		attrs = existing
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	err = replaceFile(target, data, attrs)
+	if err != nil && isReplaceError(err) {
+		return writeFileInPlace(target, data, attrs.perm)
+	}
+
+	return err
+}
+
+// resolveWriteTarget follows the symlinks of the named file, even dangling ones,
+// and returns the path of the file to write
+func resolveWriteTarget(name string) (string, error) {
+	for links := 0; links <= maxSymlinks; links++ {
+		fi, err := os.Lstat(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if fi.Mode()&fs.ModeSymlink == 0 {
+			return name, nil
+		}
+
+		link, err := os.Readlink(name)
+		if err != nil {
+			return "", err
+		}
+
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(name), link)
+		}
+
+		name = link
+	}
+
+	return "", &fs.PathError{Op: "write", Path: name, Err: ErrTooManySymlinks}
+}
+
+// replaceFile writes data to a temporary file in the same directory,
+// applies attrs to it and renames it over name
+func replaceFile(name string, data []byte, attrs fileAttrs) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	// no-op once the rename succeeded
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := applyFileAttrs(tmp, attrs); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return renameFile(tmp.Name(), name)
+}
+
+// writeFileInPlace truncates the named file and writes data to it
+func writeFileInPlace(name string, data []byte, perm fs.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
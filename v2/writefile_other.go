@@ -0,0 +1,14 @@
+//go:build !unix
+
+package libhosty
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// isReplaceError reports whether err means the file cannot be replaced by rename,
+// but may still be written in place, like a file in a non-writable directory
+func isReplaceError(err error) bool {
+	return errors.Is(err, fs.ErrPermission)
+}
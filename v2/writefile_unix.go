@@ -0,0 +1,22 @@
+//go:build unix
+
+package libhosty
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isReplaceError reports whether err means the file cannot be replaced by rename,
+// but may still be written in place.
+// bind mounts fail with EBUSY, EXDEV is returned across mount points,
+// read-only or non-writable directories prevent the temporary file creation,
+// owner and extended attributes may not be settable on the temporary file
+func isReplaceError(err error) bool {
+	return errors.Is(err, syscall.EBUSY) ||
+		errors.Is(err, errors.ErrUnsupported) ||
+		errors.Is(err, syscall.EXDEV) ||
+		errors.Is(err, syscall.EACCES) ||
+		errors.Is(err, syscall.EPERM) ||
+		errors.Is(err, syscall.EROFS)
+}
@@ -0,0 +1,249 @@
+//go:build unix
+
+package libhosty
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// writeTestEntry is the entry written by the write tests
+var writeTestEntry = [3]string{"10.0.0.1", "db", ""}
+
+func TestWriteHostsFileThroughSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "hosts.real")
+	link := filepath.Join(dir, "hosts")
+
+	if err := os.WriteFile(target, []byte("127.0.0.1 localhost\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink("hosts.real", link); err != nil {
+		t.Fatal(err)
+	}
+
+	hf := newTestHostsFile(t, writeTestEntry)
+	if err := hf.WriteHostsFileTo(link); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("expected the symlink to be kept")
+	}
+
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != hf.RenderHostsFile() {
+		t.Fatalf("expected the symlink target to be written, got %q", data)
+	}
+
+	fi, err = os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode().Perm() != 0640 {
+		t.Fatalf("expected permissions to be kept, got %v", fi.Mode().Perm())
+	}
+}
+
+func TestWriteHostsFileDanglingSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "run", "hosts")
+	link := filepath.Join(dir, "hosts")
+
+	if err := os.Mkdir(filepath.Dir(target), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := newTestHostsFile(t, writeTestEntry).WriteHostsFileTo(link); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(target); err != nil {
+		t.Fatalf("expected the symlink target to be created, got %v", err)
+	}
+}
+
+func TestWriteHostsFileSymlinkLoop(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "hosts")
+
+	if err := os.Symlink("hosts", link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := newTestHostsFile(t, writeTestEntry).WriteHostsFileTo(link); err == nil {
+		t.Fatal("expected an error for a symlink loop")
+	}
+}
+
+func TestWriteHostsFileBindMount(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "hosts")
+
+	if err := os.WriteFile(p, []byte("127.0.0.1 localhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// renaming over a bind mounted file fails with EBUSY
+	defer func(rename func(string, string) error) { renameFile = rename }(renameFile)
+	renameFile = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EBUSY}
+	}
+
+	hf := newTestHostsFile(t, writeTestEntry)
+	if err := hf.WriteHostsFileTo(p); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !os.SameFile(before, after) {
+		t.Fatal("expected the file to be written in place")
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != hf.RenderHostsFile() {
+		t.Fatalf("unexpected content %q", data)
+	}
+
+	// temporary files are cleaned up
+	entries, err := os.ReadDir(filepath.Dir(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("expected only the hosts file, got %v", entries)
+	}
+}
+
+func TestWriteHostsFileAtomic(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "hosts")
+
+	if err := os.WriteFile(p, []byte("127.0.0.1 localhost\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := newTestHostsFile(t, writeTestEntry).WriteHostsFileTo(p); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if os.SameFile(before, after) {
+		t.Fatal("expected the file to be replaced")
+	}
+
+	if after.Mode().Perm() != 0600 {
+		t.Fatalf("expected permissions to be kept, got %v", after.Mode().Perm())
+	}
+}
+
+func TestWriteHostsFileNewFileAttrs(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "hosts")
+
+	hf := New(WithFileMode(0600))
+	if err := hf.WriteHostsFileTo(p); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("expected mode 0600, got %v", fi.Mode().Perm())
+	}
+
+	if os.Geteuid() != 0 {
+		t.Skip("changing owners requires root")
+	}
+
+	p = filepath.Join(t.TempDir(), "hosts")
+
+	hf = New(WithFileOwner(1234, 5678))
+	if err := hf.WriteHostsFileTo(p); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err = os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if st := fi.Sys().(*syscall.Stat_t); st.Uid != 1234 || st.Gid != 5678 {
+		t.Fatalf("expected owner 1234:5678, got %d:%d", st.Uid, st.Gid)
+	}
+}
+
+func TestWriteHostsFilePreserveOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing owners requires root")
+	}
+
+	p := filepath.Join(t.TempDir(), "hosts")
+
+	if err := os.WriteFile(p, []byte("127.0.0.1 localhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chown(p, 1234, 5678); err != nil {
+		t.Fatal(err)
+	}
+
+	// options only apply to new files
+	hf := New(WithFileOwner(0, 0), WithFileMode(0600))
+	if err := hf.WriteHostsFileTo(p); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if st := fi.Sys().(*syscall.Stat_t); st.Uid != 1234 || st.Gid != 5678 {
+		t.Fatalf("expected owner 1234:5678 to be kept, got %d:%d", st.Uid, st.Gid)
+	}
+
+	if fi.Mode().Perm() != 0644 {
+		t.Fatalf("expected mode 0644 to be kept, got %v", fi.Mode().Perm())
+	}
+}